@@ -4,25 +4,81 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"time"
 
 	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
+	"github.com/Lockwarr/WordOfWisdom/internal/pow"
 	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
 )
 
-const maxIterations = 10000000
+// Solving gives up after attemptsMargin times the attempts a challenge takes on average,
+// a solvable challenge needs more with a chance of e^-20
+const attemptsMargin = 20
 
-// Run - connect to given address and send request
-func Run(ctx context.Context, address string) error {
-	conn, err := net.Dial("tcp", address)
-	if err != nil {
-		return err
+// maxZeroBits - the iteration cap stops growing with the difficulty here, 2^40 attempts take days
+const maxZeroBits = 40
+
+// identity - name the client introduces itself with during the handshake
+const identity = "WordOfWisdom client"
+
+// errHelloUnsupported - the server hung up on the Hello or didn't know its type, it predates the handshake
+var errHelloUnsupported = errors.New("server doesn't speak hello")
+
+// Option - configures optional behaviour of the client
+type Option func(*config)
+
+type config struct {
+	encoding protocol.Encoding
+	versions []int
+	filter   protocol.QuoteFilter
+}
+
+// WithEncoding - prefers the given encoding during the handshake, json by default
+func WithEncoding(encoding protocol.Encoding) Option {
+	return func(c *config) {
+		c.encoding = encoding
 	}
-	defer conn.Close()
-	fmt.Println("connected to", address)
+}
+
+// WithProtocolVersions - limits the protocol versions offered during the handshake
+func WithProtocolVersions(versions ...int) Option {
+	return func(c *config) {
+		c.versions = versions
+	}
+}
+
+// WithQuoteFilter - asks for a quote matching the filter instead of any quote
+func WithQuoteFilter(filter protocol.QuoteFilter) Option {
+	return func(c *config) {
+		c.filter = filter
+	}
+}
 
-	quote, err := requestQuote(ctx, conn)
+// Quote - a quote received from the server, with the fields of protocol.QuotePayload so the two convert. Servers speaking a protocol version older than
+// protocol.StructuredQuotesVersion only send the text, which includes the author.
+type Quote struct {
+	ID     string
+	Text   string
+	Author string
+	// Source is the work the quote is taken from, e.g. a book or a speech
+	Source string
+	Tags   []string
+	// Language is the BCP 47 tag of the text, e.g. "en", empty when unknown
+	Language string
+}
+
+// String - the text followed by its author, formatted like protocol.QuotePayload
+func (q Quote) String() string {
+	return protocol.QuotePayload(q).String()
+}
+
+// Run - connect to given address and send request
+func Run(ctx context.Context, address string, opts ...Option) error {
+	quote, err := RequestQuote(ctx, address, opts...)
 	if err != nil {
 		return err
 	}
@@ -30,74 +86,194 @@ func Run(ctx context.Context, address string) error {
 	return nil
 }
 
-func requestQuote(ctx context.Context, conn net.Conn) (string, error) {
-	reader := bufio.NewReader(conn)
+// RequestQuote - connects to the given address and returns the quote the server sends for a solved challenge.
+// Servers that predate the handshake are asked again on a new connection, speaking protocol.LegacyVersion.
+func RequestQuote(ctx context.Context, address string, opts ...Option) (Quote, error) {
+	cfg := config{encoding: protocol.EncodingJSON, versions: protocol.SupportedVersions}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	quote, err := requestQuote(ctx, address, cfg, handshake)
+	if errors.Is(err, errHelloUnsupported) && containsVersion(cfg.versions, protocol.LegacyVersion) {
+		fmt.Printf("%v, falling back to protocol v%d\n", err, protocol.LegacyVersion)
+		return requestQuote(ctx, address, cfg, legacyHandshake)
+	}
+	return quote, err
+}
+
+// handshakeFunc - agrees on the protocol spoken over the connection
+type handshakeFunc func(conn net.Conn, cfg config) (protocol.HelloResponsePayload, protocol.Encoder, protocol.Decoder, error)
+
+// requestQuote - connects and runs a whole exchange, errors reported by the server are returned
+// wrapping *protocol.ErrorPayload so they can be matched with errors.Is against the protocol sentinels
+func requestQuote(ctx context.Context, address string, cfg config, start handshakeFunc) (Quote, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return Quote{}, err
+	}
+	defer conn.Close()
+	fmt.Println("connected to", address)
+
+	negotiated, encoder, decoder, err := start(conn, cfg)
+	if err != nil {
+		return Quote{}, err
+	}
 
 	// Request challenge
 	message := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
-	err := sendMsg(message, conn)
+	err = encoder.Encode(message)
 	if err != nil {
-		return "", fmt.Errorf("err send message: %w", err)
+		return Quote{}, fmt.Errorf("err send message: %w", err)
 	}
 
 	// We need to solve the returned challenge
-	resp, err := reader.ReadString('\n')
+	resp, err := decoder.Decode()
 	if err != nil {
-		return "", fmt.Errorf("err read connection: %w", err)
+		return Quote{}, fmt.Errorf("err read connection: %w", err)
+	}
+	if err := protocol.ParseError(resp); err != nil {
+		return Quote{}, fmt.Errorf("challenge request rejected: %w", err)
 	}
-	quoteRequest, err := handleChallengeResponse(resp)
+	quoteRequest, err := handleChallengeResponse(resp, cfg.filter)
 	if err != nil {
-		return "", fmt.Errorf("err handle challenge response: %w", err)
+		return Quote{}, fmt.Errorf("err handle challenge response: %w", err)
 	}
 
 	// Request quote with solved challenge
-	err = sendMsg(*quoteRequest, conn)
+	err = encoder.Encode(*quoteRequest)
 	if err != nil {
-		return "", fmt.Errorf("err send message: %w", err)
+		return Quote{}, fmt.Errorf("err send message: %w", err)
 	}
 
 	// Read quote response
-	respQuote, err := reader.ReadString('\n')
+	quoteResponseMessage, err := decoder.Decode()
 	if err != nil {
-		return "", fmt.Errorf("err read quote response: %w", err)
+		return Quote{}, fmt.Errorf("err read quote response: %w", err)
 	}
-	quoteResponseMessage := protocol.Message{}
-	err = json.Unmarshal([]byte(respQuote), &quoteResponseMessage)
+	payload, err := protocol.ParseQuoteResponse(negotiated.Version, quoteResponseMessage)
 	if err != nil {
-		return "", fmt.Errorf("err unmarshal quote response: %w", err)
+		return Quote{}, fmt.Errorf("quote request rejected: %w", err)
 	}
-	return quoteResponseMessage.Data, nil
+	return Quote(payload), nil
 }
 
-// handleChallengeResponse - handles proof of work challenge
-func handleChallengeResponse(resp string) (*protocol.Message, error) {
-	stamp := hashcash.Stamp{}
+// handshake - agrees on the protocol version and features with the server,
+// a rejected handshake is returned as *protocol.ErrorPayload
+func handshake(conn net.Conn, cfg config) (protocol.HelloResponsePayload, protocol.Encoder, protocol.Decoder, error) {
+	var negotiated protocol.HelloResponsePayload
+	reader := bufio.NewReader(conn)
+	// the hello itself is always json
+	encoder, decoder, err := newCodec(protocol.EncodingJSON, reader, conn)
+	if err != nil {
+		return negotiated, nil, nil, err
+	}
+
+	encodings := []protocol.Encoding{cfg.encoding}
+	for _, e := range []protocol.Encoding{protocol.EncodingJSON, protocol.EncodingBinary} {
+		if e != cfg.encoding {
+			encodings = append(encodings, e)
+		}
+	}
+	err = encoder.Encode(protocol.NewHelloMessage(protocol.HelloPayload{
+		Versions:    cfg.versions,
+		Encodings:   encodings,
+		Algorithms:  pow.Names(),
+		Compression: []string{protocol.CompressionNone},
+		Identity:    identity,
+	}))
+	if err != nil {
+		return negotiated, nil, nil, fmt.Errorf("err send hello: %w", err)
+	}
+
+	resp, err := decoder.Decode()
+	if errors.Is(err, io.EOF) {
+		return negotiated, nil, nil, fmt.Errorf("%w: connection closed after hello", errHelloUnsupported)
+	}
+	if err != nil {
+		return negotiated, nil, nil, fmt.Errorf("err read hello response: %w", err)
+	}
+	negotiated, err = protocol.ParseHelloResponse(resp)
+	if errors.Is(err, protocol.ErrUnknownType) {
+		return negotiated, nil, nil, fmt.Errorf("%w: %v", errHelloUnsupported, err)
+	}
+	if err != nil {
+		return negotiated, nil, nil, err
+	}
+	fmt.Printf("speaking protocol v%d over %s with %s\n", negotiated.Version, negotiated.Encoding, negotiated.Identity)
 
-	challengeResponseMessage := protocol.Message{}
-	err := json.Unmarshal([]byte(resp), &challengeResponseMessage)
+	encoder, decoder, err = newCodec(negotiated.Encoding, reader, conn)
+	return negotiated, encoder, decoder, err
+}
+
+// legacyHandshake - skips the Hello, speaking json and protocol.LegacyVersion like servers that predate it
+func legacyHandshake(conn net.Conn, cfg config) (protocol.HelloResponsePayload, protocol.Encoder, protocol.Decoder, error) {
+	negotiated := protocol.HelloResponsePayload{Version: protocol.LegacyVersion, Encoding: protocol.EncodingJSON}
+	encoder, decoder, err := newCodec(negotiated.Encoding, bufio.NewReader(conn), conn)
+	return negotiated, encoder, decoder, err
+}
+
+// containsVersion - whether the protocol version is one of the versions
+func containsVersion(versions []int, version int) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// newCodec - creates the encoder and decoder for the connection
+func newCodec(encoding protocol.Encoding, reader *bufio.Reader, conn net.Conn) (protocol.Encoder, protocol.Decoder, error) {
+	encoder, err := protocol.NewEncoder(encoding, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	decoder, err := protocol.NewDecoder(encoding, reader, protocol.DefaultMaxFrameSize)
 	if err != nil {
-		return nil, fmt.Errorf("err unmarshal message: %w", err)
+		return nil, nil, err
 	}
-	err = json.Unmarshal([]byte(challengeResponseMessage.Data), &stamp)
+	return encoder, decoder, nil
+}
+
+// handleChallengeResponse - handles proof of work challenge, the solution asks for a quote matching the filter
+func handleChallengeResponse(challengeResponseMessage *protocol.Message, filter protocol.QuoteFilter) (*protocol.Message, error) {
+	received := time.Now()
+	stamp := hashcash.Stamp{}
+
+	err := json.Unmarshal([]byte(challengeResponseMessage.Data), &stamp)
 	if err != nil {
 		return nil, fmt.Errorf("err unmarshal message data: %w", err)
 	}
-	solvedStamp, err := stamp.ComputeHashcash(maxIterations)
+	alg, err := pow.Lookup(stamp.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("err pick pow solver: %w", err)
+	}
+	solvedStamp, err := alg.Solve(stamp, maxIterations(stamp))
 	if err != nil {
 		return nil, fmt.Errorf("err compute hashcash: %w", err)
 	}
+	// the server would reject it anyway
+	if window := time.Duration(stamp.SolveWindow) * time.Second; window > 0 && time.Since(received) > window {
+		return nil, fmt.Errorf("err compute hashcash: took longer than the %s solve window", window)
+	}
 
-	solvedStampMarshalled, err := json.Marshal(solvedStamp)
+	quoteRequest, err := protocol.NewQuoteRequestMessage(solvedStamp, filter)
 	if err != nil {
 		return nil, fmt.Errorf("err marshal stamp: %w", err)
 	}
-	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshalled)}
 	return &quoteRequest, nil
 }
 
-// sendMsg - send protocol message to connection
-func sendMsg(msg protocol.Message, conn net.Conn) error {
-	msgStr := fmt.Sprintf("%s\n", msg.ToJsonString())
-	_, err := conn.Write([]byte(msgStr))
-	return err
+// maxIterations - the iteration cap for solving the stamp, scaled with its difficulty
+// so the raised difficulty of a loaded server or a penalized client is still solved
+func maxIterations(stamp hashcash.Stamp) int {
+	zeroBits := stamp.ZeroBits()
+	if zeroBits > maxZeroBits {
+		zeroBits = maxZeroBits
+	}
+	if zeroBits < 0 {
+		zeroBits = 0
+	}
+	return attemptsMargin << zeroBits
 }
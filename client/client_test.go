@@ -1,23 +1,33 @@
 package client_test
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
+	"net"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/Lockwarr/WordOfWisdom/client"
+	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
+	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
+	"github.com/Lockwarr/WordOfWisdom/internal/quotes"
 	"github.com/Lockwarr/WordOfWisdom/internal/repository"
 	"github.com/Lockwarr/WordOfWisdom/server"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// serverAddr - address of the server the clients under test connect to
+var serverAddr string
+
 func TestMain(m *testing.M) {
 	repo := repository.NewInMemoryDB()
-	tcpSrvr := server.NewTCPServer("localhost", "8000", repo)
+	tcpSrvr := server.NewTCPServer("localhost", "0", repo, quotes.NewEmbeddedStore())
 	go tcpSrvr.Start(context.Background())
-	time.Sleep(time.Second)
+	serverAddr = tcpSrvr.Addr().String()
 
 	code := m.Run()
 	tcpSrvr.Stop()
@@ -42,8 +52,202 @@ func TestClientRun(t *testing.T) {
 	//Arrange
 
 	// Act
-	err := client.Run(context.Background(), ":8000")
+	err := client.Run(context.Background(), serverAddr)
+
+	// Assert
+	assert.Equal(t, nil, err)
+}
+
+func TestClientRunWithBinaryEncoding(t *testing.T) {
+	//Arrange
+
+	// Act
+	err := client.Run(context.Background(), serverAddr, client.WithEncoding(protocol.EncodingBinary))
 
 	// Assert
 	assert.Equal(t, nil, err)
 }
+
+func TestRequestQuote(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []client.Option
+		structured bool
+	}{
+		{name: "structured quote", structured: true},
+		{name: "structured quote over binary", opts: []client.Option{client.WithEncoding(protocol.EncodingBinary)}, structured: true},
+		{name: "plain text quote of version 1", opts: []client.Option{client.WithProtocolVersions(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			quote, err := client.RequestQuote(context.Background(), serverAddr, tt.opts...)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.NotEmpty(t, quote.Text)
+			if tt.structured {
+				assert.NotEmpty(t, quote.ID)
+				assert.NotEmpty(t, quote.Author)
+				assert.Equal(t, "en", quote.Language)
+				assert.NotContains(t, quote.Text, quote.Author)
+			} else {
+				assert.Empty(t, quote.ID)
+				assert.Contains(t, quote.Text, " — ")
+			}
+		})
+	}
+}
+
+func TestRequestQuoteWithFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    protocol.QuoteFilter
+		wantedErr error
+	}{
+		{name: "by tag", filter: protocol.QuoteFilter{Tag: "stoicism"}},
+		{name: "by author", filter: protocol.QuoteFilter{Author: "Rob Pike", Languages: []string{"en"}}},
+		{name: "no match", filter: protocol.QuoteFilter{Tag: "cooking"}, wantedErr: protocol.ErrQuoteNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			quote, err := client.RequestQuote(context.Background(), serverAddr, client.WithQuoteFilter(tt.filter))
+
+			// Assert
+			if tt.wantedErr != nil {
+				assert.True(t, errors.Is(err, tt.wantedErr))
+				return
+			}
+			assert.NoError(t, err)
+			if tt.filter.Tag != "" {
+				assert.Contains(t, quote.Tags, tt.filter.Tag)
+			}
+			if tt.filter.Author != "" {
+				assert.Equal(t, tt.filter.Author, quote.Author)
+			}
+		})
+	}
+}
+
+func TestClientRunWithUnsupportedProtocolVersion(t *testing.T) {
+	//Arrange
+
+	// Act
+	err := client.Run(context.Background(), serverAddr, client.WithProtocolVersions(99))
+
+	// Assert
+	assert.True(t, errors.Is(err, protocol.ErrUnsupportedVersion))
+}
+
+func TestClientRunWithRejectedChallengeRequest(t *testing.T) {
+	// Arrange
+	// a server that finishes the handshake and then turns the client away
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		decoder, _ := protocol.NewDecoder(protocol.EncodingJSON, reader, 0)
+		encoder, _ := protocol.NewEncoder(protocol.EncodingJSON, conn)
+		if _, err := decoder.Decode(); err != nil {
+			return
+		}
+		_ = encoder.Encode(protocol.NewHelloResponseMessage(protocol.HelloResponsePayload{
+			Version: protocol.Version, Encoding: protocol.EncodingJSON, Algorithm: "hashcash-sha1", Compression: protocol.CompressionNone,
+		}))
+		if _, err := decoder.Decode(); err != nil {
+			return
+		}
+		_ = encoder.Encode(protocol.NewErrorMessage(protocol.NewError(protocol.CodeRateLimited, "slow down").WithRetryAfter(5 * time.Second)))
+	}()
+
+	// Act
+	err = client.Run(context.Background(), l.Addr().String())
+
+	// Assert
+	assert.True(t, errors.Is(err, protocol.ErrRateLimited))
+	var payload *protocol.ErrorPayload
+	assert.True(t, errors.As(err, &payload))
+	assert.Equal(t, 5, payload.RetryAfter)
+}
+
+// startLegacyServer - a server that predates the handshake, it answers the Hello with hangUp
+// and serves the plain text quote for any solution
+func startLegacyServer(t *testing.T, hangUp func(conn net.Conn, encoder protocol.Encoder)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				decoder, _ := protocol.NewDecoder(protocol.EncodingJSON, bufio.NewReader(conn), 0)
+				encoder, _ := protocol.NewEncoder(protocol.EncodingJSON, conn)
+				for {
+					msg, err := decoder.Decode()
+					if err != nil {
+						return
+					}
+					switch msg.Type {
+					case protocol.ChallengeRequest:
+						stamp := hashcash.Stamp{Version: hashcash.VersionHexZeros, ZerosCount: 1, Date: time.Now().Unix(), Resource: "127.0.0.1", Rand: "123456789"}
+						stampMarshaled, _ := json.Marshal(stamp)
+						_ = encoder.Encode(protocol.Message{Type: protocol.ChallengeResponse, Data: string(stampMarshaled)})
+					case protocol.QuoteRequest:
+						_ = encoder.Encode(protocol.Message{Type: protocol.QuoteResponse, Data: "Clear is better than clever. — Rob Pike"})
+					default:
+						hangUp(conn, encoder)
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestRequestQuoteFromServerWithoutHello(t *testing.T) {
+	tests := []struct {
+		name   string
+		hangUp func(conn net.Conn, encoder protocol.Encoder)
+	}{
+		{name: "closes the connection", hangUp: func(conn net.Conn, encoder protocol.Encoder) {}},
+		{name: "rejects the unknown type", hangUp: func(conn net.Conn, encoder protocol.Encoder) {
+			_ = encoder.Encode(protocol.NewErrorMessage(protocol.NewError(protocol.CodeUnknownType, "unknown request type %d", protocol.Hello)))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			addr := startLegacyServer(t, tt.hangUp)
+
+			// Act
+			quote, err := client.RequestQuote(context.Background(), addr)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, "Clear is better than clever. — Rob Pike", quote.Text)
+		})
+	}
+}
+
+func TestRequestQuoteFromServerWithoutHelloWithoutLegacyVersion(t *testing.T) {
+	// Arrange
+	addr := startLegacyServer(t, func(conn net.Conn, encoder protocol.Encoder) {})
+
+	// Act
+	_, err := client.RequestQuote(context.Background(), addr, client.WithProtocolVersions(protocol.Version))
+
+	// Assert
+	assert.Error(t, err)
+}
@@ -5,9 +5,11 @@ import (
 	"context"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/Lockwarr/WordOfWisdom/client"
+	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
 )
 
 // TODO: start using config file or env variables
@@ -15,6 +17,21 @@ const address = "localhost:8080"
 
 func main() {
 	mode := os.Getenv("CLIENT_MODE")
+	// CLIENT_ENCODING is json (default) or binary
+	var opts []client.Option
+	if encoding := os.Getenv("CLIENT_ENCODING"); encoding != "" {
+		opts = append(opts, client.WithEncoding(protocol.Encoding(encoding)))
+	}
+	// QUOTE_ID, QUOTE_TAG, QUOTE_AUTHOR and QUOTE_LANGUAGES (comma separated, in order of preference) pick the quote
+	filter := protocol.QuoteFilter{
+		ID:     os.Getenv("QUOTE_ID"),
+		Tag:    os.Getenv("QUOTE_TAG"),
+		Author: os.Getenv("QUOTE_AUTHOR"),
+	}
+	if languages := os.Getenv("QUOTE_LANGUAGES"); languages != "" {
+		filter.Languages = strings.Split(languages, ",")
+	}
+	opts = append(opts, client.WithQuoteFilter(filter))
 	if mode == "local" {
 		scanner := bufio.NewScanner(os.Stdin)
 		for scanner.Scan() {
@@ -24,7 +41,7 @@ func main() {
 			case "help":
 				log.Println("Available commands: request-quote, start, run, exit, help")
 			case "request-quote", "start", "run":
-				err := client.Run(context.Background(), address)
+				err := client.Run(context.Background(), address, opts...)
 				if err != nil {
 					panic(err)
 				}
@@ -35,7 +52,7 @@ func main() {
 	} else if mode == "docker" {
 		time.Sleep(time.Second)
 		// requests one quote and that's it
-		err := client.Run(context.Background(), "server:8080")
+		err := client.Run(context.Background(), "server:8080", opts...)
 		if err != nil {
 			panic(err)
 		}
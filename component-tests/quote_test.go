@@ -11,12 +11,11 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"strings"
 	"testing"
-	"time"
 
 	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
 	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
+	"github.com/Lockwarr/WordOfWisdom/internal/quotes"
 	"github.com/Lockwarr/WordOfWisdom/internal/repository"
 	"github.com/Lockwarr/WordOfWisdom/server"
 	"github.com/cucumber/godog"
@@ -56,6 +55,8 @@ func init() {
 }
 
 type quoteFeature struct {
+	serverAddr         string
+	quotes             quotes.Store
 	tcpConn            net.Conn
 	challenge          string
 	quote              string
@@ -64,10 +65,10 @@ type quoteFeature struct {
 
 func (f *quoteFeature) InitializeTestSuite(ctx *godog.TestSuiteContext) {
 	repo := repository.NewInMemoryDB()
-	tcpSrvr := server.NewTCPServer("localhost", "8080", repo)
+	f.quotes = quotes.NewEmbeddedStore()
+	tcpSrvr := server.NewTCPServer("localhost", "0", repo, f.quotes)
 	go tcpSrvr.Start(context.Background())
-	// give time to start the server
-	time.Sleep(1 * time.Second)
+	f.serverAddr = tcpSrvr.Addr().String()
 }
 
 func (f *quoteFeature) InitializeScenario(ctx *godog.ScenarioContext) {
@@ -87,7 +88,7 @@ func (f *quoteFeature) InitializeScenario(ctx *godog.ScenarioContext) {
 // Given
 func (f *quoteFeature) aTcpConnectionWithServerRunning() error {
 	var err error
-	f.tcpConn, err = net.Dial("tcp", ":8080")
+	f.tcpConn, err = net.Dial("tcp", f.serverAddr)
 	return err
 }
 
@@ -154,7 +155,7 @@ func (f *quoteFeature) isValidQuote(isValid string) error {
 		if err != nil {
 			return err
 		}
-		if !strings.Contains(quoteResponseMessage.Data, "Quote") {
+		if !f.isServedQuote(quoteResponseMessage.Data) {
 			fmt.Println(quoteResponseMessage)
 			return fmt.Errorf("Invalid response")
 		}
@@ -166,3 +167,12 @@ func (f *quoteFeature) isValidQuote(isValid string) error {
 
 	return nil
 }
+
+func (f *quoteFeature) isServedQuote(data string) bool {
+	for _, q := range f.quotes.All() {
+		if data == (protocol.QuotePayload{Text: q.Text, Author: q.Author}).String() {
+			return true
+		}
+	}
+	return false
+}
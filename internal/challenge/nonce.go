@@ -0,0 +1,19 @@
+package challenge
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NonceSize - random bytes in a challenge nonce, large enough that nonces never repeat and can't be guessed ahead
+const NonceSize = 16
+
+// NewNonce - returns a hex encoded nonce from crypto/rand to issue a challenge with.
+// Hex keeps it usable as a Hashcash resource and as an argument to the hashcash tool.
+func NewNonce() (string, error) {
+	b := make([]byte, NonceSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,160 @@
+// Signs issued challenges so solutions can be verified without remembering every challenge
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid challenge signature")
+	ErrExpired          = errors.New("challenge expired")
+	ErrBindingMismatch  = errors.New("challenge was issued to another client")
+)
+
+const (
+	// randSeparator splits the nonce, binding fingerprint and signature in a signed rand
+	randSeparator = "."
+	// fingerprintSize - bytes of the binding's hash carried in the rand, enough to tell bindings apart
+	fingerprintSize = 9
+	// maxClockSkew tolerates servers behind a load balancer whose clocks are slightly apart
+	maxClockSkew = 5 * time.Second
+)
+
+// Config - configures the Signer
+type Config struct {
+	// Secret derives the signing keys, every server instance behind a load balancer needs the same one.
+	// A random secret is generated when empty, which only works for a single instance.
+	Secret []byte
+	// Rotation is how long a derived signing key is used before the next one takes over
+	Rotation time.Duration
+	// MaxAge is how long an issued challenge can be redeemed
+	MaxAge time.Duration
+}
+
+// DefaultConfig - hourly keys, challenges have to be solved within 5 minutes
+func DefaultConfig() Config {
+	return Config{
+		Rotation: time.Hour,
+		MaxAge:   5 * time.Minute,
+	}
+}
+
+// Signer - signs the fields of issued challenges together with the binding of the client they were issued to.
+// The signing key is derived from the secret and the rotation period the challenge was issued in,
+// so keys rotate without any coordination between server instances.
+type Signer struct {
+	secret   []byte
+	rotation time.Duration
+	maxAge   time.Duration
+}
+
+// NewSigner - creates a signer, zero config values fall back to DefaultConfig
+func NewSigner(cfg Config) (*Signer, error) {
+	defaults := DefaultConfig()
+	// keys are derived per whole second periods
+	if cfg.Rotation < time.Second {
+		cfg.Rotation = defaults.Rotation
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = defaults.MaxAge
+	}
+	secret := cfg.Secret
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &Signer{secret: secret, rotation: cfg.Rotation, maxAge: cfg.MaxAge}, nil
+}
+
+// MaxAge - how long an issued challenge can be redeemed, spent solutions have to be tracked at least as long
+func (s *Signer) MaxAge() time.Duration {
+	return s.maxAge
+}
+
+// Sign - returns the stamp with a fingerprint of the binding and the signature appended to its rand,
+// the stamp's rand has to be a nonce from NewNonce. The binding describes the client, e.g. its address.
+func (s *Signer) Sign(stamp hashcash.Stamp, binding string) hashcash.Stamp {
+	fp := fingerprint(binding)
+	sig := base64.RawURLEncoding.EncodeToString(s.mac(stamp, stamp.Rand, fp))
+	stamp.Rand = strings.Join([]string{stamp.Rand, fp, sig}, randSeparator)
+	return stamp
+}
+
+// Verify - checks that the stamp was signed by a signer sharing the secret, for the same binding and is still redeemable.
+// Returns the nonce of the challenge to track spent solutions with.
+func (s *Signer) Verify(stamp hashcash.Stamp, binding string) (string, error) {
+	parts := strings.Split(stamp.Rand, randSeparator)
+	if len(parts) != 3 {
+		return "", ErrInvalidSignature
+	}
+	nonce, fp := parts[0], parts[1]
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	if !hmac.Equal(sig, s.mac(stamp, nonce, fp)) {
+		return "", ErrInvalidSignature
+	}
+
+	// the fingerprint is covered by the signature, so a mismatch means a genuine challenge solved for someone else
+	if fp != fingerprint(binding) {
+		return "", ErrBindingMismatch
+	}
+	// the date is covered by the signature, so it can be trusted from here on
+	issued := time.Unix(stamp.Date, 0)
+	if time.Since(issued) > s.maxAge || time.Until(issued) > maxClockSkew {
+		return "", ErrExpired
+	}
+	return nonce, nil
+}
+
+// mac - signs every field of the challenge the client isn't allowed to change, only the counter is left out
+func (s *Signer) mac(stamp hashcash.Stamp, nonce, fingerprint string) []byte {
+	mac := hmac.New(sha256.New, s.key(stamp.Date))
+	writeInt(mac, int64(stamp.Version))
+	writeInt(mac, int64(stamp.ZerosCount))
+	writeInt(mac, stamp.Date)
+	writeString(mac, stamp.Resource)
+	writeString(mac, stamp.Algorithm)
+	writeString(mac, nonce)
+	writeString(mac, fingerprint)
+	return mac.Sum(nil)
+}
+
+// fingerprint - a short hash of the binding, so the rand doesn't carry e.g. the client's address in the clear
+func fingerprint(binding string) string {
+	sum := sha256.Sum256([]byte(binding))
+	return base64.RawURLEncoding.EncodeToString(sum[:fingerprintSize])
+}
+
+// key - derives the signing key of the rotation period the date falls into
+func (s *Signer) key(date int64) []byte {
+	period := date / int64(s.rotation/time.Second)
+	mac := hmac.New(sha256.New, s.secret)
+	writeInt(mac, period)
+	return mac.Sum(nil)
+}
+
+// writeInt and writeString length prefix every field, so the boundaries between fields can't be shifted
+func writeInt(w io.Writer, v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	_, _ = w.Write(b[:])
+}
+
+func writeString(w io.Writer, v string) {
+	writeInt(w, int64(len(v)))
+	_, _ = w.Write([]byte(v))
+}
@@ -0,0 +1,115 @@
+package challenge_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/challenge"
+	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSignedStamp(t *testing.T, signer *challenge.Signer, date time.Time, client string) hashcash.Stamp {
+	nonce, err := challenge.NewNonce()
+	assert.NoError(t, err)
+	stamp := hashcash.Stamp{
+		Version:    hashcash.VersionHexZeros,
+		ZerosCount: 5,
+		Date:       date.Unix(),
+		Resource:   "empty",
+		Rand:       nonce,
+		Algorithm:  "hashcash-sha1",
+	}
+	return signer.Sign(stamp, client)
+}
+
+func TestSignerVerify(t *testing.T) {
+	// Arrange
+	signer, err := challenge.NewSigner(challenge.Config{Secret: []byte("secret")})
+	assert.NoError(t, err)
+	// another instance behind the load balancer
+	sibling, err := challenge.NewSigner(challenge.Config{Secret: []byte("secret")})
+	assert.NoError(t, err)
+	stranger, err := challenge.NewSigner(challenge.Config{Secret: []byte("other secret")})
+	assert.NoError(t, err)
+	stamp := newSignedStamp(t, signer, time.Now(), "10.0.0.1")
+	solved := stamp
+	solved.Counter = 42
+	easier := stamp
+	easier.ZerosCount = 1
+	otherNonce := stamp
+	otherNonce.Rand = "1" + stamp.Rand
+	// the fingerprint of another client swapped in
+	rebound := solved
+	rebound.Rand = strings.Replace(stamp.Rand, strings.Split(stamp.Rand, ".")[1], strings.Split(newSignedStamp(t, signer, time.Now(), "10.0.0.2").Rand, ".")[1], 1)
+
+	tests := []struct {
+		name      string
+		signer    *challenge.Signer
+		stamp     hashcash.Stamp
+		client    string
+		wantedErr error
+	}{
+		{name: "solved by the client it was issued to", signer: signer, stamp: solved, client: "10.0.0.1"},
+		{name: "verified by a sibling instance", signer: sibling, stamp: solved, client: "10.0.0.1"},
+		{name: "signed with another secret", signer: stranger, stamp: solved, client: "10.0.0.1", wantedErr: challenge.ErrInvalidSignature},
+		{name: "submitted by another client", signer: signer, stamp: solved, client: "10.0.0.2", wantedErr: challenge.ErrBindingMismatch},
+		{name: "lowered difficulty", signer: signer, stamp: easier, client: "10.0.0.1", wantedErr: challenge.ErrInvalidSignature},
+		{name: "rebound to another client", signer: signer, stamp: rebound, client: "10.0.0.2", wantedErr: challenge.ErrInvalidSignature},
+		{name: "changed nonce", signer: signer, stamp: otherNonce, client: "10.0.0.1", wantedErr: challenge.ErrInvalidSignature},
+		{name: "unsigned rand", signer: signer, stamp: hashcash.Stamp{Rand: "12345"}, client: "10.0.0.1", wantedErr: challenge.ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			nonce, err := tt.signer.Verify(tt.stamp, tt.client)
+
+			// Assert
+			if tt.wantedErr != nil {
+				assert.True(t, errors.Is(err, tt.wantedErr))
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotEmpty(t, nonce)
+			assert.Contains(t, tt.stamp.Rand, nonce)
+		})
+	}
+}
+
+func TestSignerVerifyExpired(t *testing.T) {
+	// Arrange
+	signer, err := challenge.NewSigner(challenge.Config{MaxAge: time.Minute, Rotation: time.Minute})
+	assert.NoError(t, err)
+	// signed with the key of an earlier rotation period
+	stale := newSignedStamp(t, signer, time.Now().Add(-2*time.Minute), "10.0.0.1")
+	futuristic := newSignedStamp(t, signer, time.Now().Add(time.Hour), "10.0.0.1")
+
+	// Act
+	_, staleErr := signer.Verify(stale, "10.0.0.1")
+	_, futuristicErr := signer.Verify(futuristic, "10.0.0.1")
+
+	// Assert
+	assert.True(t, errors.Is(staleErr, challenge.ErrExpired))
+	assert.True(t, errors.Is(futuristicErr, challenge.ErrExpired))
+}
+
+func TestNewNonce(t *testing.T) {
+	// Arrange
+	seen := map[string]bool{}
+
+	// Act
+	for i := 0; i < 1000; i++ {
+		nonce, err := challenge.NewNonce()
+		assert.NoError(t, err)
+		seen[nonce] = true
+	}
+
+	// Assert
+	assert.Len(t, seen, 1000)
+	for nonce := range seen {
+		assert.Len(t, nonce, 2*challenge.NonceSize)
+	}
+}
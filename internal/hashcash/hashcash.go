@@ -1,17 +1,20 @@
 package hashcash
 
 import (
-	"context"
 	"crypto/sha1"
 	"fmt"
+	"math/bits"
 	"strconv"
 	"strings"
-	"time"
-
-	"github.com/Lockwarr/WordOfWisdom/internal/repository"
 )
 
-const zeroByte = 48 // '0'
+// Stamp versions, the version is part of the hashed string so the difficulty mode can't be changed by the client
+const (
+	// VersionHexZeros - ZerosCount is the count of leading zero hex characters of the digest
+	VersionHexZeros = 1
+	// VersionZeroBits - ZerosCount is the count of leading zero bits of the digest
+	VersionZeroBits = 2
+)
 
 // Stamp - represents hashcash stamp
 type Stamp struct {
@@ -21,6 +24,11 @@ type Stamp struct {
 	Resource   string `json:"resource"`
 	Rand       string `json:"rand"`
 	Counter    int    `json:"counter"`
+	// Algorithm is the pow algorithm the stamp has to be solved with, empty means SHA-1 hashcash
+	Algorithm string `json:"algorithm,omitempty"`
+	// SolveWindow is how many seconds the server accepts the solution for, counted from when it issued the challenge.
+	// Only informs the client, it isn't hashed and the server enforces its own window.
+	SolveWindow int `json:"solveWindow,omitempty"`
 }
 
 // ToString - converts stamp to hash string
@@ -31,7 +39,7 @@ func (s Stamp) ToString() string {
 // HashToStamp converts hashstring to stamp
 func HashToStamp(hash string) (Stamp, error) {
 	splitHash := strings.Split(hash, ":")
-	if len(splitHash) < 6 {
+	if len(splitHash) < 7 {
 		return Stamp{}, fmt.Errorf("invalid hash")
 	}
 	version, err := strconv.Atoi(splitHash[0])
@@ -61,20 +69,31 @@ func HashToStamp(hash string) (Stamp, error) {
 	}, nil
 }
 
-// IsHashCorrect - checks that hash has leading <zerosCount> zeros
-func (s *Stamp) IsHashSolved() bool {
-	actualZerosCount := 0
-	hashString := sha1Hash(s.ToString())
-	if s.ZerosCount > len(hashString) {
-		return false
+// ZeroBits - the count of leading zero bits the stamp asks for, solving takes 2^ZeroBits attempts on average
+func (s Stamp) ZeroBits() int {
+	if s.Version != VersionZeroBits {
+		// every hex character is 4 bits
+		return s.ZerosCount * 4
 	}
-	for _, ch := range hashString[:s.ZerosCount] {
-		if ch != zeroByte {
-			return false
+	return s.ZerosCount
+}
+
+// IsHashCorrect - checks that hash has leading <zerosCount> zeros, counted as bits or hex characters depending on the version
+func (s *Stamp) IsHashSolved() bool {
+	digest := sha1.Sum([]byte(s.ToString()))
+	return LeadingZeroBits(digest[:]) >= s.ZeroBits()
+}
+
+// LeadingZeroBits - counts the leading zero bits of a digest
+func LeadingZeroBits(digest []byte) int {
+	count := 0
+	for _, b := range digest {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
 		}
-		actualZerosCount++
+		count += 8
 	}
-	return s.ZerosCount <= actualZerosCount
+	return count
 }
 
 // ComputeHashcash - calculates correct hashcash by bruteforce
@@ -90,34 +109,3 @@ func (s Stamp) ComputeHashcash(maxIterations int) (Stamp, error) {
 	}
 	return s, fmt.Errorf("max iterations exceeded")
 }
-
-// ValidStamp - checks if the stamp is valid
-func (p *Stamp) ValidStamp(ctx context.Context, stampForValidation Stamp, repo repository.Repository) bool {
-	// TODO: add debug logs on errors
-	if stampForValidation.Date > time.Now().Add(2*24*time.Hour).Unix() {
-		return false // futuristic
-	}
-	if stampForValidation.Date < time.Now().Add(-28*(24*time.Hour)).Unix() {
-		return false // expired
-	}
-
-	if !p.IsHashSolved() {
-		return false // insufficient zeroes
-	}
-
-	v, err := strconv.ParseInt(stampForValidation.Rand, 10, 64)
-	if err != nil {
-		return false // invalid rand
-	}
-
-	_, err = repo.GetIndicator(ctx, v)
-	return err == nil
-}
-
-// sha1Hash - calculates sha1 hash from given string
-func sha1Hash(data string) string {
-	h := sha1.New()
-	h.Write([]byte(data))
-	bs := h.Sum(nil)
-	return fmt.Sprintf("%x", bs)
-}
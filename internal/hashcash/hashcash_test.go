@@ -1,14 +1,12 @@
 package hashcash_test
 
 import (
-	"context"
 	"crypto/sha1"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
-	"github.com/Lockwarr/WordOfWisdom/internal/repository"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,6 +45,73 @@ func TestIsHashSolved(t *testing.T) {
 	assert.Equal(t, true, solved)
 }
 
+func TestIsHashSolvedWithZeroBits(t *testing.T) {
+	// Arrange
+	stamp, err := hashcash.Stamp{
+		Version:    hashcash.VersionZeroBits,
+		ZerosCount: 10,
+		Date:       int64(1656246214),
+		Resource:   "test",
+		Rand:       "123456789",
+	}.ComputeHashcash(0)
+	assert.NoError(t, err)
+	digest := sha1.Sum([]byte(stamp.ToString()))
+
+	// Act
+	solved := stamp.IsHashSolved()
+
+	// Assert
+	assert.True(t, solved)
+	assert.GreaterOrEqual(t, hashcash.LeadingZeroBits(digest[:]), 10)
+	// the version is part of the hashed string, so the same counter doesn't solve a hex stamp
+	stamp.Version = hashcash.VersionHexZeros
+	assert.False(t, stamp.IsHashSolved())
+}
+
+func TestZeroBits(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name     string
+		stamp    hashcash.Stamp
+		expected int
+	}{
+		{name: "hex zeros", stamp: hashcash.Stamp{Version: hashcash.VersionHexZeros, ZerosCount: 5}, expected: 20},
+		{name: "zero bits", stamp: hashcash.Stamp{Version: hashcash.VersionZeroBits, ZerosCount: 5}, expected: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			zeroBits := tt.stamp.ZeroBits()
+
+			// Assert
+			assert.Equal(t, tt.expected, zeroBits)
+		})
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name     string
+		digest   []byte
+		expected int
+	}{
+		{name: "no leading zeros", digest: []byte{0x80, 0x00}, expected: 0},
+		{name: "partial byte", digest: []byte{0x1f, 0xff}, expected: 3},
+		{name: "whole byte and partial", digest: []byte{0x00, 0x0f}, expected: 12},
+		{name: "all zeros", digest: []byte{0x00, 0x00}, expected: 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			count := hashcash.LeadingZeroBits(tt.digest)
+
+			// Assert
+			assert.Equal(t, tt.expected, count)
+		})
+	}
+}
+
 func TestHashToStamp(t *testing.T) {
 	// Arrange
 	tests := []struct {
@@ -92,6 +157,12 @@ func TestHashToStamp(t *testing.T) {
 			expectedStamp: hashcash.Stamp{},
 			wantedErr:     fmt.Errorf("invalid hash"),
 		},
+		{
+			name:          "hash without counter field",
+			hashString:    "1:5:1546300800:testMail@asd.ss::123456789",
+			expectedStamp: hashcash.Stamp{},
+			wantedErr:     fmt.Errorf("invalid hash"),
+		},
 		{
 			name:          "hash with invalid counter",
 			hashString:    "1:5:1546300800:testMail@asd.ss::123456789:",
@@ -111,88 +182,6 @@ func TestHashToStamp(t *testing.T) {
 	}
 }
 
-func TestValidStamp(t *testing.T) {
-	// Arrange
-	repo := repository.NewInMemoryDB()
-	_ = repo.AddIndicator(context.Background(), 123456789)
-	tests := []struct {
-		name          string
-		stamp         hashcash.Stamp
-		expectedValid bool
-	}{
-		// As of June 26th, 2022, this test is valid, but it will be invalid after 28 days pass.
-		{
-			name: "valid solved stamp",
-			stamp: hashcash.Stamp{
-				Version:    1,
-				ZerosCount: 5,
-				Date:       int64(1656246214),
-				Resource:   "test",
-				Rand:       "123456789",
-				Counter:    808598,
-			},
-			expectedValid: true,
-		},
-		{
-			name: "unsolved stamp",
-			stamp: hashcash.Stamp{
-				Version:    1,
-				ZerosCount: 5,
-				Date:       time.Now().Unix(),
-				Resource:   "test",
-				Rand:       "123456789",
-				Counter:    1,
-			},
-			expectedValid: false,
-		},
-		{
-			name: "expired stamp",
-			stamp: hashcash.Stamp{
-				Version:    1,
-				ZerosCount: 5,
-				Date:       time.Now().Add(-time.Hour * 24 * 30).Unix(),
-				Resource:   "test",
-				Rand:       "123456789",
-				Counter:    1,
-			},
-			expectedValid: false,
-		},
-		{
-			name: "futuristic stamp",
-			stamp: hashcash.Stamp{
-				Version:    1,
-				ZerosCount: 5,
-				Date:       time.Now().Add(time.Hour * 24 * 5).Unix(),
-				Resource:   "test",
-				Rand:       "123456789",
-				Counter:    1,
-			},
-			expectedValid: false,
-		},
-		{
-			name: "invalid rand in stamp",
-			stamp: hashcash.Stamp{
-				Version:    1,
-				ZerosCount: 5,
-				Date:       time.Now().Unix(),
-				Resource:   "test",
-				Rand:       "invalid",
-				Counter:    1,
-			},
-			expectedValid: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Act
-			valid := tt.stamp.ValidStamp(context.Background(), tt.stamp, repo)
-
-			// Assert
-			assert.Equal(t, tt.expectedValid, valid)
-		})
-	}
-}
-
 func TestComputeHashcash(t *testing.T) {
 	// Arrange
 	tests := []struct {
@@ -0,0 +1,213 @@
+package hashcash
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date layouts allowed by the Hashcash spec, YYMMDD[hhmm[ss]]
+const (
+	tokenDateLayoutDay    = "060102"
+	tokenDateLayoutMinute = "0601021504"
+	tokenDateLayoutSecond = "060102150405"
+)
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+var (
+	ErrInvalidToken = errors.New("invalid hashcash token")
+)
+
+// Extension - single name[=val1[,val2...]] entry of the Hashcash v1 ext field
+type Extension struct {
+	Name   string
+	Values []string
+}
+
+// Token - stamp in the standard Hashcash wire format, as produced by the hashcash command line tool.
+//
+//	v1: 1:bits:date:resource:ext:rand:counter
+//	v0: 0:date:resource:counter
+//
+// Fields are kept as they were received, so String returns exactly the text that was hashed.
+type Token struct {
+	Version  int
+	Bits     int
+	Date     string
+	Resource string
+	Ext      []Extension
+	Rand     string
+	Counter  string
+}
+
+// IsToken - reports whether data looks like a Hashcash v0/v1 stamp rather than a json encoded Stamp
+func IsToken(data string) bool {
+	return strings.HasPrefix(data, "1:") || strings.HasPrefix(data, "0:")
+}
+
+// ParseToken - decodes a Hashcash v1 or v0 stamp
+func ParseToken(data string) (Token, error) {
+	fields := strings.Split(strings.TrimSpace(data), ":")
+	switch fields[0] {
+	case "1":
+		if len(fields) != 7 {
+			return Token{}, fmt.Errorf("%w: v1 needs 7 fields, got %d", ErrInvalidToken, len(fields))
+		}
+		bits, err := strconv.Atoi(fields[1])
+		if err != nil || bits < 0 || bits > sha1.Size*8 {
+			return Token{}, fmt.Errorf("%w: invalid bits", ErrInvalidToken)
+		}
+		ext, err := parseExtensions(fields[4])
+		if err != nil {
+			return Token{}, err
+		}
+		token := Token{Version: 1, Bits: bits, Date: fields[2], Resource: fields[3], Ext: ext, Rand: fields[5], Counter: fields[6]}
+		if !isBase64(token.Rand) {
+			return Token{}, fmt.Errorf("%w: invalid rand", ErrInvalidToken)
+		}
+		if token.Counter == "" || !isBase64(token.Counter) {
+			return Token{}, fmt.Errorf("%w: invalid counter", ErrInvalidToken)
+		}
+		if _, err := token.Time(); err != nil {
+			return Token{}, err
+		}
+		return token, nil
+	case "0":
+		if len(fields) != 4 {
+			return Token{}, fmt.Errorf("%w: v0 needs 4 fields, got %d", ErrInvalidToken, len(fields))
+		}
+		token := Token{Version: 0, Date: fields[1], Resource: fields[2], Counter: fields[3]}
+		if token.Counter == "" {
+			return Token{}, fmt.Errorf("%w: invalid counter", ErrInvalidToken)
+		}
+		if _, err := token.Time(); err != nil {
+			return Token{}, err
+		}
+		return token, nil
+	default:
+		return Token{}, fmt.Errorf("%w: unsupported version %q", ErrInvalidToken, fields[0])
+	}
+}
+
+// String - encodes the token in the Hashcash wire format
+func (t Token) String() string {
+	if t.Version == 0 {
+		return fmt.Sprintf("0:%s:%s:%s", t.Date, t.Resource, t.Counter)
+	}
+	return fmt.Sprintf("1:%d:%s:%s:%s:%s:%s", t.Bits, t.Date, t.Resource, formatExtensions(t.Ext), t.Rand, t.Counter)
+}
+
+// Time - returns the UTC time the token was minted at
+func (t Token) Time() (time.Time, error) {
+	var layout string
+	switch len(t.Date) {
+	case len(tokenDateLayoutDay):
+		layout = tokenDateLayoutDay
+	case len(tokenDateLayoutMinute):
+		layout = tokenDateLayoutMinute
+	case len(tokenDateLayoutSecond):
+		layout = tokenDateLayoutSecond
+	default:
+		return time.Time{}, fmt.Errorf("%w: invalid date", ErrInvalidToken)
+	}
+	date, err := time.Parse(layout, t.Date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid date", ErrInvalidToken)
+	}
+	return date, nil
+}
+
+// Value - returns the count of leading zero bits of the SHA-1 digest of the token
+func (t Token) Value() int {
+	digest := sha1.Sum([]byte(t.String()))
+	return LeadingZeroBits(digest[:])
+}
+
+// Solves - checks the token is worth at least the required bits. v1 tokens also have to be worth the bits they claim.
+func (t Token) Solves(requiredBits int) bool {
+	if t.Version == 1 {
+		if t.Bits < requiredBits {
+			return false
+		}
+		requiredBits = t.Bits
+	}
+	return t.Value() >= requiredBits
+}
+
+// MintToken - computes a Hashcash v1 token for the resource,
+// maxIterations to prevent endless computing (0 or -1 to disable it)
+func MintToken(resource string, bits int, ext []Extension, now time.Time, maxIterations int) (Token, error) {
+	randBytes := make([]byte, 12)
+	if _, err := rand.Read(randBytes); err != nil {
+		return Token{}, fmt.Errorf("err generate rand: %w", err)
+	}
+	token := Token{
+		Version:  1,
+		Bits:     bits,
+		Date:     now.UTC().Format(tokenDateLayoutSecond),
+		Resource: resource,
+		Ext:      ext,
+		Rand:     base64.StdEncoding.EncodeToString(randBytes),
+	}
+	counter := make([]byte, 8)
+	for i := 0; i <= maxIterations || maxIterations <= 0; i++ {
+		binary.BigEndian.PutUint64(counter, uint64(i))
+		// the spec doesn't mandate a counter encoding, drop leading zero bytes to keep it short
+		trimmed := counter
+		for len(trimmed) > 1 && trimmed[0] == 0 {
+			trimmed = trimmed[1:]
+		}
+		token.Counter = base64.RawStdEncoding.EncodeToString(trimmed)
+		if token.Solves(bits) {
+			return token, nil
+		}
+	}
+	return token, fmt.Errorf("max iterations exceeded")
+}
+
+func parseExtensions(field string) ([]Extension, error) {
+	if field == "" {
+		return nil, nil
+	}
+	var extensions []Extension
+	for _, part := range strings.Split(field, ";") {
+		ext := Extension{Name: part}
+		if i := strings.Index(part, "="); i >= 0 {
+			ext.Name = part[:i]
+			ext.Values = strings.Split(part[i+1:], ",")
+		}
+		if ext.Name == "" {
+			return nil, fmt.Errorf("%w: invalid extension %q", ErrInvalidToken, part)
+		}
+		extensions = append(extensions, ext)
+	}
+	return extensions, nil
+}
+
+func formatExtensions(extensions []Extension) string {
+	parts := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		if len(ext.Values) == 0 {
+			parts = append(parts, ext.Name)
+			continue
+		}
+		parts = append(parts, ext.Name+"="+strings.Join(ext.Values, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+func isBase64(s string) bool {
+	for _, ch := range s {
+		if !strings.ContainsRune(base64Chars, ch) {
+			return false
+		}
+	}
+	return true
+}
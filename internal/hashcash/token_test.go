@@ -0,0 +1,137 @@
+package hashcash_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Examples from the Hashcash specification, minted with the hashcash tool
+const (
+	specV1Token = "1:20:1303030600:adam@cypherspace.org::McMybZIhxKXu57jd:ckvi"
+	specV0Token = "0:030626:adam@cypherspace.org:6470e06d773e05a8"
+)
+
+func TestParseToken(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name          string
+		data          string
+		expectedToken hashcash.Token
+		wantedErr     error
+	}{
+		{
+			name: "spec v1 token",
+			data: specV1Token,
+			expectedToken: hashcash.Token{
+				Version:  1,
+				Bits:     20,
+				Date:     "1303030600",
+				Resource: "adam@cypherspace.org",
+				Rand:     "McMybZIhxKXu57jd",
+				Counter:  "ckvi",
+			},
+		},
+		{
+			name: "spec v0 token",
+			data: specV0Token,
+			expectedToken: hashcash.Token{
+				Version:  0,
+				Date:     "030626",
+				Resource: "adam@cypherspace.org",
+				Counter:  "6470e06d773e05a8",
+			},
+		},
+		{
+			name: "v1 token with extensions",
+			data: "1:20:221018120000:foo:McMy;name=v1,v2:abc:1",
+			expectedToken: hashcash.Token{
+				Version:  1,
+				Bits:     20,
+				Date:     "221018120000",
+				Resource: "foo",
+				Ext: []hashcash.Extension{
+					{Name: "McMy"},
+					{Name: "name", Values: []string{"v1", "v2"}},
+				},
+				Rand:    "abc",
+				Counter: "1",
+			},
+		},
+		{name: "missing counter field", data: "1:20:1303030600:adam@cypherspace.org::McMybZIhxKXu57jd", wantedErr: hashcash.ErrInvalidToken},
+		{name: "invalid date", data: "1:20:13030306:adam@cypherspace.org::McMybZIhxKXu57jd:ckvi", wantedErr: hashcash.ErrInvalidToken},
+		{name: "invalid bits", data: "1:x:1303030600:adam@cypherspace.org::McMybZIhxKXu57jd:ckvi", wantedErr: hashcash.ErrInvalidToken},
+		{name: "invalid rand", data: "1:20:1303030600:adam@cypherspace.org::Mc!:ckvi", wantedErr: hashcash.ErrInvalidToken},
+		{name: "empty extension", data: "1:20:1303030600:adam@cypherspace.org:a;;b:McMybZIhxKXu57jd:ckvi", wantedErr: hashcash.ErrInvalidToken},
+		{name: "unsupported version", data: "2:20:1303030600:adam@cypherspace.org::McMybZIhxKXu57jd:ckvi", wantedErr: hashcash.ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			token, err := hashcash.ParseToken(tt.data)
+
+			// Assert
+			if tt.wantedErr != nil {
+				assert.True(t, errors.Is(err, tt.wantedErr))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedToken, token)
+			assert.Equal(t, tt.data, token.String())
+		})
+	}
+}
+
+func TestTokenSolves(t *testing.T) {
+	// Arrange
+	v1, err := hashcash.ParseToken(specV1Token)
+	assert.NoError(t, err)
+	v0, err := hashcash.ParseToken(specV0Token)
+	assert.NoError(t, err)
+
+	// Act
+	v1Value, v0Value := v1.Value(), v0.Value()
+
+	// Assert
+	assert.GreaterOrEqual(t, v1Value, 20)
+	assert.GreaterOrEqual(t, v0Value, 32)
+	assert.True(t, v1.Solves(20))
+	assert.False(t, v1.Solves(24), "v1 tokens can't be worth more than the bits they claim")
+	assert.True(t, v0.Solves(32))
+}
+
+func TestTokenTime(t *testing.T) {
+	// Arrange
+	token, err := hashcash.ParseToken(specV1Token)
+	assert.NoError(t, err)
+
+	// Act
+	minted, err := token.Time()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2013, time.March, 3, 6, 0, 0, 0, time.UTC), minted)
+}
+
+func TestMintToken(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	ext := []hashcash.Extension{{Name: "client", Values: []string{"wow"}}}
+
+	// Act
+	token, err := hashcash.MintToken("123456789", 16, ext, now, 0)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, token.Solves(16))
+	parsed, err := hashcash.ParseToken(token.String())
+	assert.NoError(t, err)
+	assert.Equal(t, token, parsed)
+	minted, err := parsed.Time()
+	assert.NoError(t, err)
+	assert.Equal(t, now.UTC().Truncate(time.Second), minted)
+}
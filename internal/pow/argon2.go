@@ -0,0 +1,56 @@
+package pow
+
+import (
+	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters, clients and servers have to agree on them so they are not configurable
+const (
+	argon2Time    = 1
+	argon2Memory  = 8 * 1024 // KiB
+	argon2Threads = 1
+	argon2KeyLen  = 32
+)
+
+type argon2id struct{}
+
+// NewArgon2id - memory-hard proof of work, difficulty is the count of leading zero bits of the Argon2id key.
+// Every attempt needs 8 MiB of memory which makes it expensive to solve on GPUs and ASICs.
+func NewArgon2id() Algorithm {
+	return argon2id{}
+}
+
+// Name - returns the algorithm identifier
+func (argon2id) Name() string {
+	return Argon2id
+}
+
+// NewChallenge - creates an unsolved Argon2id stamp
+func (a argon2id) NewChallenge(resource, rand string, difficulty int) hashcash.Stamp {
+	return newStamp(a.Name(), hashcash.VersionZeroBits, resource, rand, difficulty)
+}
+
+// Solve - computes the counter
+func (a argon2id) Solve(stamp hashcash.Stamp, maxIterations int) (hashcash.Stamp, error) {
+	return solve(stamp, maxIterations, a.Verify)
+}
+
+// Verify - checks the leading zero bits of the Argon2id key derived from the stamp
+func (argon2id) Verify(stamp hashcash.Stamp) bool {
+	salt := []byte(stamp.Resource + ":" + stamp.Rand)
+	key := argon2.IDKey([]byte(stamp.ToString()), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return hashcash.LeadingZeroBits(key) >= stamp.ZerosCount
+}
+
+// DifficultyPerHexZero - difficulty is counted in bits
+func (argon2id) DifficultyPerHexZero() int {
+	return bitDifficulty
+}
+
+// AttemptCost - deriving the key with 8 MiB of memory costs thousands of hashes, so Argon2id challenges
+// take about as long to solve as hash challenges with 13 bits more
+func (argon2id) AttemptCost() int {
+	return argon2AttemptCost
+}
@@ -0,0 +1,93 @@
+package pow
+
+import (
+	"crypto/sha256"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
+)
+
+type sha1Hashcash struct {
+	version int
+}
+
+// NewSHA1Hashcash - classic hashcash, difficulty is the count of leading zero hex characters of the SHA-1 digest
+func NewSHA1Hashcash() Algorithm {
+	return sha1Hashcash{version: hashcash.VersionHexZeros}
+}
+
+// NewSHA1HashcashBits - SHA-1 hashcash with versioned stamps where difficulty is the count of leading zero bits,
+// so every step doubles the work instead of multiplying it by 16
+func NewSHA1HashcashBits() Algorithm {
+	return sha1Hashcash{version: hashcash.VersionZeroBits}
+}
+
+// Name - returns the algorithm identifier
+func (sha1Hashcash) Name() string {
+	return SHA1Hashcash
+}
+
+// NewChallenge - creates an unsolved SHA-1 hashcash stamp
+func (a sha1Hashcash) NewChallenge(resource, rand string, difficulty int) hashcash.Stamp {
+	return newStamp(a.Name(), a.version, resource, rand, difficulty)
+}
+
+// Solve - computes the hashcash counter
+func (sha1Hashcash) Solve(stamp hashcash.Stamp, maxIterations int) (hashcash.Stamp, error) {
+	return stamp.ComputeHashcash(maxIterations)
+}
+
+// Verify - checks the leading zeros of the SHA-1 digest, the stamp version tells whether they are bits or hex characters
+func (sha1Hashcash) Verify(stamp hashcash.Stamp) bool {
+	return stamp.IsHashSolved()
+}
+
+// DifficultyPerHexZero - 1 for hex characters, 4 for bits
+func (a sha1Hashcash) DifficultyPerHexZero() int {
+	if a.version == hashcash.VersionZeroBits {
+		return bitDifficulty
+	}
+	return hexZeroDifficulty
+}
+
+// AttemptCost - an attempt is a single hash
+func (sha1Hashcash) AttemptCost() int {
+	return hashAttemptCost
+}
+
+type sha256Hashcash struct{}
+
+// NewSHA256Hashcash - hashcash over SHA-256, difficulty is the count of leading zero bits of the digest
+func NewSHA256Hashcash() Algorithm {
+	return sha256Hashcash{}
+}
+
+// Name - returns the algorithm identifier
+func (sha256Hashcash) Name() string {
+	return SHA256Hashcash
+}
+
+// NewChallenge - creates an unsolved SHA-256 hashcash stamp
+func (a sha256Hashcash) NewChallenge(resource, rand string, difficulty int) hashcash.Stamp {
+	return newStamp(a.Name(), hashcash.VersionZeroBits, resource, rand, difficulty)
+}
+
+// Solve - computes the hashcash counter
+func (a sha256Hashcash) Solve(stamp hashcash.Stamp, maxIterations int) (hashcash.Stamp, error) {
+	return solve(stamp, maxIterations, a.Verify)
+}
+
+// Verify - checks the leading zero bits of the SHA-256 digest
+func (sha256Hashcash) Verify(stamp hashcash.Stamp) bool {
+	digest := sha256.Sum256([]byte(stamp.ToString()))
+	return hashcash.LeadingZeroBits(digest[:]) >= stamp.ZerosCount
+}
+
+// DifficultyPerHexZero - difficulty is counted in bits
+func (sha256Hashcash) DifficultyPerHexZero() int {
+	return bitDifficulty
+}
+
+// AttemptCost - an attempt is a single hash
+func (sha256Hashcash) AttemptCost() int {
+	return hashAttemptCost
+}
@@ -0,0 +1,97 @@
+// Proof of work algorithms the server can challenge clients with
+package pow
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
+)
+
+// Names of the supported algorithms, advertised in hashcash.Stamp.Algorithm
+const (
+	SHA1Hashcash   = "hashcash-sha1"
+	SHA256Hashcash = "hashcash-sha256"
+	Argon2id       = "argon2id"
+)
+
+var (
+	ErrUnknownAlgorithm      = errors.New("unknown pow algorithm")
+	ErrMaxIterationsExceeded = errors.New("max iterations exceeded")
+)
+
+// Algorithm - proof of work scheme used to issue, solve and verify challenges
+type Algorithm interface {
+	// Name - identifier advertised to clients in the challenge
+	Name() string
+	// NewChallenge - creates an unsolved challenge, difficulty unit depends on the algorithm
+	NewChallenge(resource, rand string, difficulty int) hashcash.Stamp
+	// Solve - brute forces the challenge, maxIterations <= 0 disables the limit
+	Solve(stamp hashcash.Stamp, maxIterations int) (hashcash.Stamp, error)
+	// Verify - checks that the challenge is solved
+	Verify(stamp hashcash.Stamp) bool
+	// DifficultyPerHexZero - the difficulty that takes as much work as one leading zero hex character,
+	// i.e. 16 attempts on average: 1 for algorithms counting hex characters, 4 for the ones counting bits
+	DifficultyPerHexZero() int
+	// AttemptCost - how much difficulty, in the unit of the algorithm, an attempt costs more than a SHA-1 hash.
+	// Algorithms counting bits return log2 of the ratio, the server lowers their difficulty by it.
+	AttemptCost() int
+}
+
+var algorithms = map[string]Algorithm{
+	SHA1Hashcash:   NewSHA1Hashcash(),
+	SHA256Hashcash: NewSHA256Hashcash(),
+	Argon2id:       NewArgon2id(),
+}
+
+// Lookup - returns the algorithm advertised in a challenge, stamps without one are SHA-1 hashcash
+func Lookup(name string) (Algorithm, error) {
+	if name == "" {
+		name = SHA1Hashcash
+	}
+	alg, ok := algorithms[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, name)
+	}
+	return alg, nil
+}
+
+// Names - returns the names of all supported algorithms
+func Names() []string {
+	return []string{SHA1Hashcash, SHA256Hashcash, Argon2id}
+}
+
+// Difficulty units of the algorithms, in leading zeros of the digest
+const (
+	hexZeroDifficulty = 1
+	bitDifficulty     = 4
+	// hashAttemptCost is the AttemptCost of the hash based algorithms, a SHA-256 digest costs about as much as a SHA-1 one
+	hashAttemptCost = 0
+	// argon2AttemptCost - an Argon2id key takes about 4.5ms, 2^13 times a SHA-1 hash
+	argon2AttemptCost = 13
+)
+
+// newStamp - creates the unsolved stamp shared by all algorithms
+func newStamp(name string, version int, resource, rand string, difficulty int) hashcash.Stamp {
+	return hashcash.Stamp{
+		Version:    version,
+		ZerosCount: difficulty,
+		Date:       time.Now().Unix(),
+		Resource:   resource,
+		Rand:       rand,
+		Counter:    0,
+		Algorithm:  name,
+	}
+}
+
+// solve - increments the counter until verify succeeds
+func solve(stamp hashcash.Stamp, maxIterations int, verify func(hashcash.Stamp) bool) (hashcash.Stamp, error) {
+	for stamp.Counter <= maxIterations || maxIterations <= 0 {
+		if verify(stamp) {
+			return stamp, nil
+		}
+		stamp.Counter++
+	}
+	return stamp, ErrMaxIterationsExceeded
+}
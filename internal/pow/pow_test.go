@@ -0,0 +1,119 @@
+package pow_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/pow"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name         string
+		algorithm    string
+		expectedName string
+		wantedErr    error
+	}{
+		{name: "legacy stamp without algorithm", algorithm: "", expectedName: pow.SHA1Hashcash},
+		{name: "sha1", algorithm: pow.SHA1Hashcash, expectedName: pow.SHA1Hashcash},
+		{name: "sha256", algorithm: pow.SHA256Hashcash, expectedName: pow.SHA256Hashcash},
+		{name: "argon2id", algorithm: pow.Argon2id, expectedName: pow.Argon2id},
+		{name: "unknown", algorithm: "md5", wantedErr: pow.ErrUnknownAlgorithm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			alg, err := pow.Lookup(tt.algorithm)
+
+			// Assert
+			if tt.wantedErr != nil {
+				assert.True(t, errors.Is(err, tt.wantedErr))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedName, alg.Name())
+		})
+	}
+}
+
+func TestSolveAndVerify(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name       string
+		algorithm  pow.Algorithm
+		difficulty int
+	}{
+		{name: "sha1", algorithm: pow.NewSHA1Hashcash(), difficulty: 3},
+		{name: "sha1 bits", algorithm: pow.NewSHA1HashcashBits(), difficulty: 12},
+		{name: "sha256", algorithm: pow.NewSHA256Hashcash(), difficulty: 12},
+		{name: "argon2id", algorithm: pow.NewArgon2id(), difficulty: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge := tt.algorithm.NewChallenge("test", "123456789", tt.difficulty)
+
+			// Act
+			solved, err := tt.algorithm.Solve(challenge, 0)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.algorithm.Name(), solved.Algorithm)
+			assert.True(t, tt.algorithm.Verify(solved))
+		})
+	}
+}
+
+func TestVerifyRejectsUnsolvedStamp(t *testing.T) {
+	// Arrange
+	alg := pow.NewSHA256Hashcash()
+	challenge := alg.NewChallenge("test", "123456789", 12)
+	solved, err := alg.Solve(challenge, 0)
+	assert.NoError(t, err)
+
+	// Act
+	solved.Counter++
+	valid := alg.Verify(solved)
+
+	// Assert
+	assert.False(t, valid)
+}
+
+func TestSolveMaxIterationsExceeded(t *testing.T) {
+	// Arrange
+	alg := pow.NewSHA256Hashcash()
+	challenge := alg.NewChallenge("test", "123456789", 64)
+
+	// Act
+	_, err := alg.Solve(challenge, 10)
+
+	// Assert
+	assert.Equal(t, pow.ErrMaxIterationsExceeded, err)
+}
+
+func TestDifficultyPerHexZero(t *testing.T) {
+	tests := []struct {
+		name              string
+		alg               pow.Algorithm
+		wanted            int
+		wantedAttemptCost int
+	}{
+		{name: "sha1 hex zeros", alg: pow.NewSHA1Hashcash(), wanted: 1, wantedAttemptCost: 0},
+		{name: "sha1 bits", alg: pow.NewSHA1HashcashBits(), wanted: 4, wantedAttemptCost: 0},
+		{name: "sha256", alg: pow.NewSHA256Hashcash(), wanted: 4, wantedAttemptCost: 0},
+		{name: "argon2id", alg: pow.NewArgon2id(), wanted: 4, wantedAttemptCost: 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			perHexZero := tt.alg.DifficultyPerHexZero()
+			attemptCost := tt.alg.AttemptCost()
+
+			// Assert
+			assert.Equal(t, tt.wanted, perHexZero)
+			assert.Equal(t, tt.wantedAttemptCost, attemptCost)
+		})
+	}
+}
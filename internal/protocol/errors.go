@@ -0,0 +1,99 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ErrorCode - machine readable reason a request was rejected, part of the wire format so never rename them
+type ErrorCode string
+
+const (
+	CodeChallengeExpired       ErrorCode = "challenge_expired"
+	CodeInvalidSolution        ErrorCode = "invalid_solution"
+	CodeReplayDetected         ErrorCode = "replay_detected"
+	CodeBindingMismatch        ErrorCode = "binding_mismatch"
+	CodeRateLimited            ErrorCode = "rate_limited"
+	CodeTooManyConnections     ErrorCode = "too_many_connections"
+	CodeTimeout                ErrorCode = "timeout"
+	CodeQuoteNotFound          ErrorCode = "quote_not_found"
+	CodeMalformedMessage       ErrorCode = "malformed_message"
+	CodeUnknownType            ErrorCode = "unknown_type"
+	CodeUnsupportedVersion     ErrorCode = "unsupported_version"
+	CodeUnsupportedEncoding    ErrorCode = "unsupported_encoding"
+	CodeUnsupportedAlgorithm   ErrorCode = "unsupported_algorithm"
+	CodeUnsupportedCompression ErrorCode = "unsupported_compression"
+	CodeInternal               ErrorCode = "internal"
+)
+
+// Sentinels to match received errors against with errors.Is, only the code is compared
+var (
+	ErrChallengeExpired       = &ErrorPayload{Code: CodeChallengeExpired}
+	ErrInvalidSolution        = &ErrorPayload{Code: CodeInvalidSolution}
+	ErrReplayDetected         = &ErrorPayload{Code: CodeReplayDetected}
+	ErrBindingMismatch        = &ErrorPayload{Code: CodeBindingMismatch}
+	ErrRateLimited            = &ErrorPayload{Code: CodeRateLimited}
+	ErrTooManyConnections     = &ErrorPayload{Code: CodeTooManyConnections}
+	ErrTimeout                = &ErrorPayload{Code: CodeTimeout}
+	ErrQuoteNotFound          = &ErrorPayload{Code: CodeQuoteNotFound}
+	ErrMalformedMessage       = &ErrorPayload{Code: CodeMalformedMessage}
+	ErrUnknownType            = &ErrorPayload{Code: CodeUnknownType}
+	ErrUnsupportedVersion     = &ErrorPayload{Code: CodeUnsupportedVersion}
+	ErrUnsupportedEncoding    = &ErrorPayload{Code: CodeUnsupportedEncoding}
+	ErrUnsupportedAlgorithm   = &ErrorPayload{Code: CodeUnsupportedAlgorithm}
+	ErrUnsupportedCompression = &ErrorPayload{Code: CodeUnsupportedCompression}
+	ErrInternal               = &ErrorPayload{Code: CodeInternal}
+)
+
+// ErrorPayload - why the server rejected a request, sent as an Error message right before it closes the connection
+type ErrorPayload struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// RetryAfter - seconds the client should wait before trying again, zero when retrying won't help
+	RetryAfter int `json:"retryAfter,omitempty"`
+}
+
+// NewError - creates an error with the given code and a human readable message
+func NewError(code ErrorCode, format string, args ...interface{}) *ErrorPayload {
+	return &ErrorPayload{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// WithRetryAfter - returns a copy of the error that asks the client to wait before retrying
+func (e *ErrorPayload) WithRetryAfter(d time.Duration) *ErrorPayload {
+	withRetry := *e
+	withRetry.RetryAfter = int((d + time.Second - 1) / time.Second)
+	return &withRetry
+}
+
+// Error - implements error
+func (e *ErrorPayload) Error() string {
+	if e.Message == "" {
+		return string(e.Code)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Is - errors with the same code are equal, so received errors match the sentinels
+func (e *ErrorPayload) Is(target error) bool {
+	t, ok := target.(*ErrorPayload)
+	return ok && t.Code == e.Code
+}
+
+// NewErrorMessage - encodes the error as an Error message
+func NewErrorMessage(payload *ErrorPayload) Message {
+	payloadBytes, _ := json.Marshal(payload)
+	return Message{Type: Error, Data: string(payloadBytes)}
+}
+
+// ParseError - returns the error carried by an Error message, nil for any other message type
+func ParseError(msg *Message) error {
+	if msg.Type != Error {
+		return nil
+	}
+	var payload ErrorPayload
+	if err := json.Unmarshal([]byte(msg.Data), &payload); err != nil || payload.Code == "" {
+		return &ErrorPayload{Code: CodeInternal, Message: fmt.Sprintf("unreadable error from server: %q", msg.Data)}
+	}
+	return &payload
+}
@@ -0,0 +1,44 @@
+package protocol_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorMessageRoundTrip(t *testing.T) {
+	// Arrange
+	sent := protocol.NewError(protocol.CodeRateLimited, "too many requests from %s", "127.0.0.0/24").WithRetryAfter(1500 * time.Millisecond)
+	msg := protocol.NewErrorMessage(sent)
+
+	// Act
+	err := protocol.ParseError(&msg)
+
+	// Assert
+	assert.Equal(t, protocol.Error, msg.Type)
+	var received *protocol.ErrorPayload
+	assert.True(t, errors.As(err, &received))
+	assert.Equal(t, sent, received)
+	assert.Equal(t, 2, received.RetryAfter)
+	assert.True(t, errors.Is(fmt.Errorf("err read quote response: %w", err), protocol.ErrRateLimited))
+	assert.False(t, errors.Is(err, protocol.ErrInvalidSolution))
+}
+
+func TestParseErrorOfOtherMessages(t *testing.T) {
+	// Arrange
+	quote := protocol.Message{Type: protocol.QuoteResponse, Data: "quote"}
+	garbage := protocol.Message{Type: protocol.Error, Data: "not json"}
+
+	// Act
+	quoteErr := protocol.ParseError(&quote)
+	garbageErr := protocol.ParseError(&garbage)
+
+	// Assert
+	assert.NoError(t, quoteErr)
+	assert.True(t, errors.Is(garbageErr, protocol.ErrInternal))
+}
@@ -0,0 +1,172 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encoding - how messages are framed on the wire
+type Encoding string
+
+const (
+	// EncodingJSON - newline delimited json, easy to debug with netcat
+	EncodingJSON Encoding = "json"
+	// EncodingBinary - magic byte, version, type and a length prefixed payload
+	EncodingBinary Encoding = "binary"
+)
+
+const (
+	// FrameMagic starts every binary frame, json messages start with '{' so the two can't be confused
+	FrameMagic byte = 'W'
+	// FrameVersion is the version of the binary frame layout
+	FrameVersion byte = 1
+	// DefaultMaxFrameSize limits the size of a single message
+	DefaultMaxFrameSize = 64 * 1024
+
+	frameHeaderSize = 7 // magic + version + type + uint32 length
+)
+
+var (
+	ErrFrameTooLarge   = errors.New("frame too large")
+	ErrInvalidFrame    = errors.New("invalid frame")
+	ErrUnknownEncoding = errors.New("unknown encoding")
+)
+
+// Encoder - writes messages to a connection
+type Encoder interface {
+	Encode(msg Message) error
+}
+
+// Decoder - reads messages from a connection
+type Decoder interface {
+	Decode() (*Message, error)
+}
+
+// DetectEncoding - peeks at the first byte sent by the peer to pick the encoding it uses
+func DetectEncoding(r *bufio.Reader) (Encoding, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if first[0] == FrameMagic {
+		return EncodingBinary, nil
+	}
+	return EncodingJSON, nil
+}
+
+// NewEncoder - creates an encoder for the given encoding
+func NewEncoder(encoding Encoding, w io.Writer) (Encoder, error) {
+	switch encoding {
+	case EncodingJSON:
+		return &jsonEncoder{w: w}, nil
+	case EncodingBinary:
+		return &binaryEncoder{w: w}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, encoding)
+	}
+}
+
+// NewDecoder - creates a decoder for the given encoding that rejects messages larger than maxFrameSize
+func NewDecoder(encoding Encoding, r *bufio.Reader, maxFrameSize int) (Decoder, error) {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	switch encoding {
+	case EncodingJSON:
+		return &jsonDecoder{r: r, maxFrameSize: maxFrameSize}, nil
+	case EncodingBinary:
+		return &binaryDecoder{r: r, maxFrameSize: maxFrameSize}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, encoding)
+	}
+}
+
+type jsonEncoder struct {
+	w io.Writer
+}
+
+// Encode - writes the message as a json line
+func (e *jsonEncoder) Encode(msg Message) error {
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(msgBytes, '\n'))
+	return err
+}
+
+type jsonDecoder struct {
+	r            *bufio.Reader
+	maxFrameSize int
+}
+
+// Decode - reads a single json line, without buffering more than maxFrameSize bytes
+func (d *jsonDecoder) Decode() (*Message, error) {
+	var line []byte
+	for {
+		chunk, err := d.r.ReadSlice('\n')
+		if len(line)+len(chunk) > d.maxFrameSize+1 {
+			return nil, ErrFrameTooLarge
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	return ParseMessage(line)
+}
+
+type binaryEncoder struct {
+	w io.Writer
+}
+
+// Encode - writes the message as a single binary frame
+func (e *binaryEncoder) Encode(msg Message) error {
+	if msg.Type < 0 || msg.Type > 0xff {
+		return fmt.Errorf("%w: type %d doesn't fit in a frame", ErrInvalidFrame, msg.Type)
+	}
+	frame := make([]byte, frameHeaderSize+len(msg.Data))
+	frame[0] = FrameMagic
+	frame[1] = FrameVersion
+	frame[2] = byte(msg.Type)
+	binary.BigEndian.PutUint32(frame[3:frameHeaderSize], uint32(len(msg.Data)))
+	copy(frame[frameHeaderSize:], msg.Data)
+	_, err := e.w.Write(frame)
+	return err
+}
+
+type binaryDecoder struct {
+	r            *bufio.Reader
+	maxFrameSize int
+}
+
+// Decode - reads a single binary frame, the payload is only allocated after its length was checked
+func (d *binaryDecoder) Decode() (*Message, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != FrameMagic {
+		return nil, fmt.Errorf("%w: bad magic byte 0x%x", ErrInvalidFrame, header[0])
+	}
+	if header[1] != FrameVersion {
+		return nil, fmt.Errorf("%w: unsupported frame version %d", ErrInvalidFrame, header[1])
+	}
+	length := binary.BigEndian.Uint32(header[3:])
+	if length > uint32(d.maxFrameSize) {
+		return nil, ErrFrameTooLarge
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(d.r, payload); err != nil {
+		return nil, err
+	}
+	return &Message{Type: int(header[2]), Data: string(payload)}, nil
+}
@@ -0,0 +1,114 @@
+package protocol_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name     string
+		encoding protocol.Encoding
+	}{
+		{name: "json", encoding: protocol.EncodingJSON},
+		{name: "binary", encoding: protocol.EncodingBinary},
+	}
+	messages := []protocol.Message{
+		{Type: protocol.ChallengeRequest, Data: "empty"},
+		{Type: protocol.QuoteResponse, Data: "line\nbreak and unicode ✓"},
+		{Type: protocol.Stop, Data: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder, err := protocol.NewEncoder(tt.encoding, &buf)
+			assert.NoError(t, err)
+			for _, msg := range messages {
+				assert.NoError(t, encoder.Encode(msg))
+			}
+			reader := bufio.NewReader(&buf)
+
+			// Act
+			detected, err := protocol.DetectEncoding(reader)
+			assert.NoError(t, err)
+			decoder, err := protocol.NewDecoder(detected, reader, 0)
+			assert.NoError(t, err)
+			var decoded []protocol.Message
+			for range messages {
+				msg, err := decoder.Decode()
+				assert.NoError(t, err)
+				decoded = append(decoded, *msg)
+			}
+
+			// Assert
+			assert.Equal(t, tt.encoding, detected)
+			assert.Equal(t, messages, decoded)
+		})
+	}
+}
+
+func TestDecodeFrameTooLarge(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name     string
+		encoding protocol.Encoding
+	}{
+		{name: "json", encoding: protocol.EncodingJSON},
+		{name: "binary", encoding: protocol.EncodingBinary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder, err := protocol.NewEncoder(tt.encoding, &buf)
+			assert.NoError(t, err)
+			assert.NoError(t, encoder.Encode(protocol.Message{Type: protocol.QuoteRequest, Data: strings.Repeat("a", 10000)}))
+			decoder, err := protocol.NewDecoder(tt.encoding, bufio.NewReader(&buf), 1024)
+			assert.NoError(t, err)
+
+			// Act
+			_, err = decoder.Decode()
+
+			// Assert
+			assert.Equal(t, protocol.ErrFrameTooLarge, err)
+		})
+	}
+}
+
+func TestDecodeInvalidBinaryFrame(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{name: "bad magic", frame: []byte{'X', protocol.FrameVersion, 0, 0, 0, 0, 0}},
+		{name: "unsupported version", frame: []byte{protocol.FrameMagic, 9, 0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder, err := protocol.NewDecoder(protocol.EncodingBinary, bufio.NewReader(bytes.NewReader(tt.frame)), 0)
+			assert.NoError(t, err)
+
+			// Act
+			_, err = decoder.Decode()
+
+			// Assert
+			assert.True(t, errors.Is(err, protocol.ErrInvalidFrame))
+		})
+	}
+}
+
+func TestNewEncoderUnknownEncoding(t *testing.T) {
+	// Act
+	_, err := protocol.NewEncoder("xml", &bytes.Buffer{})
+
+	// Assert
+	assert.True(t, errors.Is(err, protocol.ErrUnknownEncoding))
+}
@@ -0,0 +1,153 @@
+package protocol
+
+import (
+	"encoding/json"
+)
+
+// Version is the protocol version spoken by this build
+const Version = StructuredQuotesVersion
+
+// LegacyVersion - the protocol version spoken by servers that predate the Hello handshake
+const LegacyVersion = 1
+
+// SupportedVersions - protocol versions this build can speak, newest first
+var SupportedVersions = []int{Version, LegacyVersion}
+
+// CompressionNone - messages are not compressed
+const CompressionNone = "none"
+
+// HelloPayload - versions and features a peer supports, sent by the client as the first message
+type HelloPayload struct {
+	Versions    []int      `json:"versions"`
+	Encodings   []Encoding `json:"encodings"`
+	Algorithms  []string   `json:"algorithms"`
+	Compression []string   `json:"compression"`
+	Identity    string     `json:"identity,omitempty"`
+}
+
+// HelloResponsePayload - version and features picked by the server, a failed handshake is answered with an Error message instead
+type HelloResponsePayload struct {
+	Version     int      `json:"version"`
+	Encoding    Encoding `json:"encoding"`
+	Algorithm   string   `json:"algorithm"`
+	Compression string   `json:"compression"`
+	Identity    string   `json:"identity,omitempty"`
+}
+
+// NewHelloMessage - encodes the payload as a Hello message
+func NewHelloMessage(payload HelloPayload) Message {
+	payloadBytes, _ := json.Marshal(payload)
+	return Message{Type: Hello, Data: string(payloadBytes)}
+}
+
+// NewHelloResponseMessage - encodes the payload as a HelloResponse message
+func NewHelloResponseMessage(payload HelloResponsePayload) Message {
+	payloadBytes, _ := json.Marshal(payload)
+	return Message{Type: HelloResponse, Data: string(payloadBytes)}
+}
+
+// ParseHello - decodes the payload of a Hello message
+func ParseHello(msg *Message) (HelloPayload, error) {
+	var payload HelloPayload
+	if msg.Type != Hello {
+		return payload, NewError(CodeMalformedMessage, "expected hello, got type %d", msg.Type)
+	}
+	if err := json.Unmarshal([]byte(msg.Data), &payload); err != nil {
+		return payload, NewError(CodeMalformedMessage, "%v", err)
+	}
+	return payload, nil
+}
+
+// ParseHelloResponse - decodes the payload of a HelloResponse message, a failed handshake is returned as *ErrorPayload
+func ParseHelloResponse(msg *Message) (HelloResponsePayload, error) {
+	var payload HelloResponsePayload
+	if err := ParseError(msg); err != nil {
+		return payload, err
+	}
+	if msg.Type != HelloResponse {
+		return payload, NewError(CodeMalformedMessage, "expected hello response, got type %d", msg.Type)
+	}
+	if err := json.Unmarshal([]byte(msg.Data), &payload); err != nil {
+		return payload, NewError(CodeMalformedMessage, "%v", err)
+	}
+	return payload, nil
+}
+
+// Negotiate - picks what the server and client both support, client preferences come first.
+// The server offers exactly one algorithm, the one it issues challenges with.
+func Negotiate(client, server HelloPayload) (HelloResponsePayload, error) {
+	resp := HelloResponsePayload{Identity: server.Identity}
+
+	for _, v := range server.Versions {
+		if containsInt(client.Versions, v) && v > resp.Version {
+			resp.Version = v
+		}
+	}
+	if resp.Version == 0 {
+		return resp, NewError(CodeUnsupportedVersion, "client speaks %v, server speaks %v", client.Versions, server.Versions)
+	}
+
+	for _, e := range client.Encodings {
+		if containsEncoding(server.Encodings, e) {
+			resp.Encoding = e
+			break
+		}
+	}
+	if resp.Encoding == "" {
+		return resp, NewError(CodeUnsupportedEncoding, "client supports %v, server supports %v", client.Encodings, server.Encodings)
+	}
+
+	for _, a := range server.Algorithms {
+		if containsString(client.Algorithms, a) {
+			resp.Algorithm = a
+			break
+		}
+	}
+	if resp.Algorithm == "" {
+		return resp, NewError(CodeUnsupportedAlgorithm, "client solves %v, server issues %v", client.Algorithms, server.Algorithms)
+	}
+
+	// clients that don't mention compression get uncompressed messages
+	clientCompression := client.Compression
+	if len(clientCompression) == 0 {
+		clientCompression = []string{CompressionNone}
+	}
+	for _, c := range clientCompression {
+		if containsString(server.Compression, c) {
+			resp.Compression = c
+			break
+		}
+	}
+	if resp.Compression == "" {
+		return resp, NewError(CodeUnsupportedCompression, "client supports %v, server supports %v", client.Compression, server.Compression)
+	}
+
+	return resp, nil
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsEncoding(values []Encoding, v Encoding) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package protocol_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testServerHello() protocol.HelloPayload {
+	return protocol.HelloPayload{
+		Versions:    []int{1, 2},
+		Encodings:   []protocol.Encoding{protocol.EncodingJSON, protocol.EncodingBinary},
+		Algorithms:  []string{"hashcash-sha1"},
+		Compression: []string{protocol.CompressionNone},
+		Identity:    "test server",
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name         string
+		client       protocol.HelloPayload
+		expectedResp protocol.HelloResponsePayload
+		expectedErr  error
+	}{
+		{
+			name: "picks highest common version and client's preferred encoding",
+			client: protocol.HelloPayload{
+				Versions:   []int{1, 2, 3},
+				Encodings:  []protocol.Encoding{protocol.EncodingBinary, protocol.EncodingJSON},
+				Algorithms: []string{"argon2id", "hashcash-sha1"},
+			},
+			expectedResp: protocol.HelloResponsePayload{
+				Version:     2,
+				Encoding:    protocol.EncodingBinary,
+				Algorithm:   "hashcash-sha1",
+				Compression: protocol.CompressionNone,
+				Identity:    "test server",
+			},
+		},
+		{
+			name: "unsupported version",
+			client: protocol.HelloPayload{
+				Versions:   []int{3},
+				Encodings:  []protocol.Encoding{protocol.EncodingJSON},
+				Algorithms: []string{"hashcash-sha1"},
+			},
+			expectedErr: protocol.ErrUnsupportedVersion,
+		},
+		{
+			name: "unsupported encoding",
+			client: protocol.HelloPayload{
+				Versions:   []int{1},
+				Encodings:  []protocol.Encoding{"xml"},
+				Algorithms: []string{"hashcash-sha1"},
+			},
+			expectedErr: protocol.ErrUnsupportedEncoding,
+		},
+		{
+			name: "unsupported algorithm",
+			client: protocol.HelloPayload{
+				Versions:   []int{1},
+				Encodings:  []protocol.Encoding{protocol.EncodingJSON},
+				Algorithms: []string{"argon2id"},
+			},
+			expectedErr: protocol.ErrUnsupportedAlgorithm,
+		},
+		{
+			name: "unsupported compression",
+			client: protocol.HelloPayload{
+				Versions:    []int{1},
+				Encodings:   []protocol.Encoding{protocol.EncodingJSON},
+				Algorithms:  []string{"hashcash-sha1"},
+				Compression: []string{"gzip"},
+			},
+			expectedErr: protocol.ErrUnsupportedCompression,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			resp, err := protocol.Negotiate(tt.client, testServerHello())
+
+			// Assert
+			if tt.expectedErr != nil {
+				assert.True(t, errors.Is(err, tt.expectedErr))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedResp, resp)
+		})
+	}
+}
+
+func TestParseHelloResponseWithError(t *testing.T) {
+	// Arrange
+	msg := protocol.NewErrorMessage(protocol.NewError(protocol.CodeUnsupportedVersion, "nope"))
+
+	// Act
+	_, err := protocol.ParseHelloResponse(&msg)
+
+	// Assert
+	assert.True(t, errors.Is(err, protocol.ErrUnsupportedVersion))
+	assert.EqualError(t, err, "unsupported_version: nope")
+}
+
+func TestParseHello(t *testing.T) {
+	// Arrange
+	hello := protocol.HelloPayload{Versions: []int{1}, Encodings: []protocol.Encoding{protocol.EncodingJSON}, Identity: "client"}
+	msg := protocol.NewHelloMessage(hello)
+	notHello := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+
+	// Act
+	parsed, err := protocol.ParseHello(&msg)
+	_, notHelloErr := protocol.ParseHello(&notHello)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, hello, parsed)
+	assert.Error(t, notHelloErr)
+}
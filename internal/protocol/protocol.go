@@ -2,19 +2,23 @@ package protocol
 
 import "encoding/json"
 
+// Message types are part of the wire format, never renumber them
 const (
-	ChallengeRequest = iota
-	ChallengeResponse
-	QuoteRequest
-	QuoteResponse
-	Stop
+	ChallengeRequest  = 0
+	ChallengeResponse = 1
+	QuoteRequest      = 2
+	QuoteResponse     = 3
+	Stop              = 4
+	Hello             = 5
+	HelloResponse     = 6
+	Error             = 7
 )
 
 // Message - represents a message to be used for communication between tcp server and its' connected clients
 type Message struct {
-	// Accepted types of messages are ChallengeRequest, ChallengeResponse, QuoteRequest, QuoteResponse, Stop
+	// Accepted types of messages are ChallengeRequest, ChallengeResponse, QuoteRequest, QuoteResponse, Stop, Hello, HelloResponse, Error
 	Type int `json:"type"`
-	// Data could be a challenge in the from of json encoded haschash.Stamp or a quote
+	// Data could be a challenge in the from of json encoded haschash.Stamp, a quote or a json encoded ErrorPayload
 	Data string `json:"data"`
 }
 
@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StructuredQuotesVersion - the first protocol version whose QuoteResponse carries a json encoded QuotePayload,
+// older versions and clients that skipped the handshake get the quote as plain text
+const StructuredQuotesVersion = 2
+
+// QuotePayload - a quote with its attribution, the schema is versioned with the protocol version
+type QuotePayload struct {
+	ID     string `json:"id"`
+	Text   string `json:"text"`
+	Author string `json:"author,omitempty"`
+	// Source is the work the quote is taken from, e.g. a book or a speech
+	Source string   `json:"source,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	// Language is the BCP 47 tag of the text, e.g. "en", empty when unknown
+	Language string `json:"language,omitempty"`
+}
+
+// String - the text followed by its author, the plain text sent to clients speaking an older version
+func (q QuotePayload) String() string {
+	if q.Author == "" {
+		return q.Text
+	}
+	return q.Text + " — " + q.Author
+}
+
+// QuoteFilter - narrows down the quote a QuoteRequest asks for, empty fields match any quote.
+// It's sent as the "filter" field of the json encoded solution, servers that don't know it serve a random quote.
+type QuoteFilter struct {
+	ID     string `json:"id,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Author string `json:"author,omitempty"`
+	// Languages are BCP 47 tags in order of preference, e.g. ["de-AT", "en"]. A region falls back to its language
+	// and a quote in any language is served when none of them is available, so they never reject a request.
+	Languages []string `json:"languages,omitempty"`
+}
+
+// IsEmpty - whether the filter matches any quote
+func (f QuoteFilter) IsEmpty() bool {
+	return f.ID == "" && f.Tag == "" && f.Author == "" && len(f.Languages) == 0
+}
+
+// NewQuoteRequestMessage - encodes a solution, e.g. a hashcash.Stamp, as a QuoteRequest for a quote matching the filter.
+// The solution has to encode to a json object.
+func NewQuoteRequestMessage(solution interface{}, filter QuoteFilter) (Message, error) {
+	solutionBytes, err := json.Marshal(solution)
+	if err != nil {
+		return Message{}, err
+	}
+	if filter.IsEmpty() {
+		return Message{Type: QuoteRequest, Data: string(solutionBytes)}, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(solutionBytes, &fields); err != nil {
+		return Message{}, fmt.Errorf("err encode solution with filter: %w", err)
+	}
+	fields["filter"], _ = json.Marshal(filter)
+	payloadBytes, _ := json.Marshal(fields)
+	return Message{Type: QuoteRequest, Data: string(payloadBytes)}, nil
+}
+
+// ParseQuoteFilter - decodes the filter of a QuoteRequest carrying a json encoded solution,
+// requests without one get an empty filter
+func ParseQuoteFilter(msg *Message) (QuoteFilter, error) {
+	var payload struct {
+		Filter QuoteFilter `json:"filter"`
+	}
+	if msg.Type != QuoteRequest {
+		return payload.Filter, NewError(CodeMalformedMessage, "expected quote request, got type %d", msg.Type)
+	}
+	if err := json.Unmarshal([]byte(msg.Data), &payload); err != nil {
+		return payload.Filter, NewError(CodeMalformedMessage, "err unmarshal quote filter: %v", err)
+	}
+	return payload.Filter, nil
+}
+
+// NewQuoteResponseMessage - encodes the quote as a QuoteResponse for a client speaking the given protocol version
+func NewQuoteResponseMessage(version int, quote QuotePayload) Message {
+	if version < StructuredQuotesVersion {
+		return Message{Type: QuoteResponse, Data: quote.String()}
+	}
+	payloadBytes, _ := json.Marshal(quote)
+	return Message{Type: QuoteResponse, Data: string(payloadBytes)}
+}
+
+// ParseQuoteResponse - decodes the quote of a QuoteResponse received over the given protocol version, a plain text
+// quote only fills Text. A rejected request is returned as *ErrorPayload.
+func ParseQuoteResponse(version int, msg *Message) (QuotePayload, error) {
+	var payload QuotePayload
+	if err := ParseError(msg); err != nil {
+		return payload, err
+	}
+	if msg.Type != QuoteResponse {
+		return payload, NewError(CodeMalformedMessage, "expected quote response, got type %d", msg.Type)
+	}
+	if version < StructuredQuotesVersion {
+		payload.Text = msg.Data
+		return payload, nil
+	}
+	if err := json.Unmarshal([]byte(msg.Data), &payload); err != nil {
+		return payload, NewError(CodeMalformedMessage, "%v", err)
+	}
+	return payload, nil
+}
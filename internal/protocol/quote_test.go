@@ -0,0 +1,117 @@
+package protocol_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteResponseRoundTrip(t *testing.T) {
+	quote := protocol.QuotePayload{
+		ID:       "pike-clear",
+		Text:     "Clear is better than clever.",
+		Author:   "Rob Pike",
+		Source:   "Go Proverbs",
+		Tags:     []string{"engineering"},
+		Language: "en",
+	}
+
+	tests := []struct {
+		name       string
+		version    int
+		wantedData string
+		wanted     protocol.QuotePayload
+	}{
+		{
+			name:       "plain text before structured quotes",
+			version:    1,
+			wantedData: "Clear is better than clever. — Rob Pike",
+			wanted:     protocol.QuotePayload{Text: "Clear is better than clever. — Rob Pike"},
+		},
+		{
+			name:       "structured quote",
+			version:    protocol.StructuredQuotesVersion,
+			wantedData: `{"id":"pike-clear","text":"Clear is better than clever.","author":"Rob Pike","source":"Go Proverbs","tags":["engineering"],"language":"en"}`,
+			wanted:     quote,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			msg := protocol.NewQuoteResponseMessage(tt.version, quote)
+			received, err := protocol.ParseQuoteResponse(tt.version, &msg)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, protocol.QuoteResponse, msg.Type)
+			assert.Equal(t, tt.wantedData, msg.Data)
+			assert.Equal(t, tt.wanted, received)
+		})
+	}
+}
+
+func TestParseQuoteResponseInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       protocol.Message
+		wantedErr error
+	}{
+		{name: "rejected request", msg: protocol.NewErrorMessage(protocol.NewError(protocol.CodeInvalidSolution, "")), wantedErr: protocol.ErrInvalidSolution},
+		{name: "other message type", msg: protocol.Message{Type: protocol.ChallengeResponse}, wantedErr: protocol.ErrMalformedMessage},
+		{name: "plain text instead of structured", msg: protocol.Message{Type: protocol.QuoteResponse, Data: "quote"}, wantedErr: protocol.ErrMalformedMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := protocol.ParseQuoteResponse(protocol.StructuredQuotesVersion, &tt.msg)
+
+			// Assert
+			assert.True(t, errors.Is(err, tt.wantedErr))
+		})
+	}
+}
+
+func TestQuoteRequestFilterRoundTrip(t *testing.T) {
+	solution := map[string]interface{}{"rand": "abc", "counter": 42}
+
+	tests := []struct {
+		name       string
+		filter     protocol.QuoteFilter
+		wantedData string
+	}{
+		{name: "no filter", wantedData: `{"counter":42,"rand":"abc"}`},
+		{
+			name:       "filter next to the solution",
+			filter:     protocol.QuoteFilter{Tag: "stoicism", Languages: []string{"de", "en"}},
+			wantedData: `{"counter":42,"filter":{"tag":"stoicism","languages":["de","en"]},"rand":"abc"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			msg, err := protocol.NewQuoteRequestMessage(solution, tt.filter)
+			assert.NoError(t, err)
+			filter, err := protocol.ParseQuoteFilter(&msg)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, protocol.QuoteRequest, msg.Type)
+			assert.Equal(t, tt.wantedData, msg.Data)
+			assert.Equal(t, tt.filter, filter)
+		})
+	}
+}
+
+func TestParseQuoteFilterInvalid(t *testing.T) {
+	// Arrange
+	msg := protocol.Message{Type: protocol.QuoteRequest, Data: `{"rand": "abc", "filter": "stoicism"}`}
+
+	// Act
+	_, err := protocol.ParseQuoteFilter(&msg)
+
+	// Assert
+	assert.True(t, errors.Is(err, protocol.ErrMalformedMessage))
+}
@@ -0,0 +1,26 @@
+package quotes
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// corpus - the quotes served when no other source is configured
+//
+//go:embed corpus.json
+var corpus []byte
+
+// NewEmbeddedStore - creates a store of the corpus built into the binary.
+// The corpus is checked by the tests, so a broken one panics instead of returning an error.
+func NewEmbeddedStore() Store {
+	var quotes []Quote
+	if err := json.Unmarshal(corpus, &quotes); err != nil {
+		panic(fmt.Sprintf("err parse embedded quotes: %v", err))
+	}
+	store, err := NewStore(quotes...)
+	if err != nil {
+		panic(fmt.Sprintf("err load embedded quotes: %v", err))
+	}
+	return store
+}
@@ -0,0 +1,179 @@
+package quotes
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tagSeparator - splits the tags column of a csv file
+const tagSeparator = ";"
+
+// NewFileStore - loads the quotes of a json, yaml or csv file, the format is picked by the file's extension.
+// json and yaml files hold a list of quotes. csv files start with a header naming the columns,
+// out of id, text, author, source, tags, language and weight, tags are separated by semicolons.
+func NewFileStore(path string) (Store, error) {
+	quotes, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewStore(quotes...)
+	if err != nil {
+		return nil, fmt.Errorf("err load %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// NewDirStore - loads every .txt file of the directory as a quote with the file's name as its ID.
+// A file may start with "Author:", "Source:", "Tags:" and "Language:" header lines, the text follows after an empty line.
+func NewDirStore(dir string) (Store, error) {
+	quotes, err := readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewStore(quotes...)
+	if err != nil {
+		return nil, fmt.Errorf("err load %s: %w", dir, err)
+	}
+	return store, nil
+}
+
+func readFile(path string) ([]Quote, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var quotes []Quote
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.NewDecoder(f).Decode(&quotes)
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(f).Decode(&quotes)
+		// an empty file
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".csv":
+		quotes, err = readCSV(f)
+	default:
+		return nil, fmt.Errorf("unsupported quotes file format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err parse %s: %w", path, err)
+	}
+	return quotes, nil
+}
+
+func readCSV(r io.Reader) ([]Quote, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["text"]; !ok {
+		return nil, fmt.Errorf("%w: csv header has no text column", ErrInvalidQuote)
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	quotes := make([]Quote, 0, len(records)-1)
+	for i, record := range records[1:] {
+		q := Quote{
+			ID:       field(record, "id"),
+			Text:     field(record, "text"),
+			Author:   field(record, "author"),
+			Source:   field(record, "source"),
+			Language: field(record, "language"),
+		}
+		if tags := field(record, "tags"); tags != "" {
+			q.Tags = strings.Split(tags, tagSeparator)
+		}
+		if weight := strings.TrimSpace(field(record, "weight")); weight != "" {
+			if q.Weight, err = strconv.ParseFloat(weight, 64); err != nil {
+				return nil, fmt.Errorf("%w: quote %d has weight %q", ErrInvalidQuote, i+1, weight)
+			}
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
+
+func readDir(dir string) ([]Quote, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	// a stable order, the ids don't depend on it but anything listing the quotes does
+	sort.Strings(paths)
+
+	quotes := make([]Quote, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		q := parseText(string(content))
+		q.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
+
+// parseText - parses the optional header lines of a text file, everything else is the quote's text.
+// Without a known header key on the first line the whole file is the text, quotes may contain colons.
+func parseText(content string) Quote {
+	var q Quote
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" && i > 0 {
+			q.Text = strings.Join(lines[i+1:], "\n")
+			return q
+		}
+		key, value, ok := cut(line, ":")
+		if !ok {
+			break
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "author":
+			q.Author = value
+		case "source":
+			q.Source = value
+		case "tags":
+			q.Tags = strings.Split(value, ",")
+		case "language":
+			q.Language = value
+		default:
+			return Quote{Text: content}
+		}
+	}
+	return Quote{Text: content}
+}
+
+// cut - strings.Cut, which needs a newer go than the module's
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,136 @@
+package quotes
+
+import (
+	"sort"
+	"strings"
+)
+
+// Query - narrows down the quotes of a store, empty fields match any quote
+type Query struct {
+	ID  string
+	Tag string
+	// Author is matched case insensitively against the whole name
+	Author string
+	// Languages are BCP 47 tags in order of preference, a region falls back to its language, e.g. "de-at" to "de".
+	// When none of them is available the quotes in any language are returned, so they never narrow down to nothing.
+	Languages []string
+}
+
+// index - positions of the quotes of a corpus by the fields they're looked up by
+type index struct {
+	byID       map[string]int
+	byTag      map[string][]int
+	byAuthor   map[string][]int
+	byLanguage map[string][]int
+}
+
+func newIndex(quotes []Quote) index {
+	idx := index{
+		byID:       make(map[string]int, len(quotes)),
+		byTag:      map[string][]int{},
+		byAuthor:   map[string][]int{},
+		byLanguage: map[string][]int{},
+	}
+	for i, q := range quotes {
+		idx.byID[q.ID] = i
+		for _, tag := range q.Tags {
+			idx.byTag[tag] = append(idx.byTag[tag], i)
+		}
+		if q.Author != "" {
+			author := strings.ToLower(q.Author)
+			idx.byAuthor[author] = append(idx.byAuthor[author], i)
+		}
+		if q.Language != "" {
+			idx.byLanguage[q.Language] = append(idx.byLanguage[q.Language], i)
+		}
+	}
+	return idx
+}
+
+// find - returns the quotes matching the query
+func (idx index) find(quotes []Quote, query Query) []Quote {
+	if query.ID == "" && query.Tag == "" && query.Author == "" && len(query.Languages) == 0 {
+		return quotes
+	}
+
+	// every field narrows down to the quotes it's indexed with, the shortest list is checked against the others
+	var lists [][]int
+	if query.ID != "" {
+		i, ok := idx.byID[strings.TrimSpace(query.ID)]
+		if !ok {
+			return nil
+		}
+		lists = append(lists, []int{i})
+	}
+	if query.Tag != "" {
+		lists = append(lists, idx.byTag[strings.ToLower(strings.TrimSpace(query.Tag))])
+	}
+	if query.Author != "" {
+		lists = append(lists, idx.byAuthor[strings.ToLower(strings.TrimSpace(query.Author))])
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	var candidates []int
+	if len(lists) == 0 {
+		candidates = make([]int, len(quotes))
+		for i := range quotes {
+			candidates[i] = i
+		}
+	} else {
+		candidates = lists[0]
+		for _, list := range lists[1:] {
+			candidates = intersect(candidates, list)
+		}
+	}
+
+	for _, language := range fallbackLanguages(query.Languages) {
+		if inLanguage := intersect(candidates, idx.byLanguage[language]); len(inLanguage) > 0 {
+			candidates = inLanguage
+			break
+		}
+	}
+
+	found := make([]Quote, len(candidates))
+	for i, position := range candidates {
+		found[i] = quotes[position]
+	}
+	return found
+}
+
+// fallbackLanguages - the preferred languages followed by the language of each region, in order and without duplicates
+func fallbackLanguages(preferred []string) []string {
+	var languages []string
+	seen := map[string]bool{}
+	add := func(language string) {
+		if language != "" && !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+	for _, language := range preferred {
+		language = strings.ToLower(strings.TrimSpace(language))
+		add(language)
+		if base, _, ok := cut(language, "-"); ok {
+			add(base)
+		}
+	}
+	return languages
+}
+
+// intersect - the positions in both ascending lists
+func intersect(a, b []int) []int {
+	var both []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			both = append(both, a[i])
+			i++
+			j++
+		}
+	}
+	return both
+}
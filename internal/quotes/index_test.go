@@ -0,0 +1,52 @@
+package quotes_test
+
+import (
+	"testing"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/quotes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreFind(t *testing.T) {
+	store, err := quotes.NewStore(
+		quotes.Quote{ID: "aurelius", Text: "one", Author: "Marcus Aurelius", Tags: []string{"stoicism"}, Language: "en"},
+		quotes.Quote{ID: "aurelius-de", Text: "eins", Author: "Marcus Aurelius", Tags: []string{"stoicism"}, Language: "de"},
+		quotes.Quote{ID: "seneca", Text: "two", Author: "Seneca", Tags: []string{"stoicism", "time"}, Language: "en"},
+		quotes.Quote{ID: "pike", Text: "three", Author: "Rob Pike", Tags: []string{"engineering"}, Language: "en"},
+		quotes.Quote{ID: "unknown", Text: "four"},
+	)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		query  quotes.Query
+		wanted []string
+	}{
+		{name: "any quote", wanted: []string{"aurelius", "aurelius-de", "seneca", "pike", "unknown"}},
+		{name: "by id", query: quotes.Query{ID: "seneca"}, wanted: []string{"seneca"}},
+		{name: "unknown id", query: quotes.Query{ID: "plato"}},
+		{name: "by tag", query: quotes.Query{Tag: "Stoicism"}, wanted: []string{"aurelius", "aurelius-de", "seneca"}},
+		{name: "by author", query: quotes.Query{Author: "rob pike"}, wanted: []string{"pike"}},
+		{name: "by tag and author", query: quotes.Query{Tag: "stoicism", Author: "Seneca"}, wanted: []string{"seneca"}},
+		{name: "by id and another author", query: quotes.Query{ID: "seneca", Author: "Rob Pike"}},
+		{name: "unknown tag", query: quotes.Query{Tag: "cooking"}},
+		{name: "in a language", query: quotes.Query{Tag: "stoicism", Languages: []string{"de"}}, wanted: []string{"aurelius-de"}},
+		{name: "in the first available language", query: quotes.Query{Tag: "stoicism", Languages: []string{"fr", "en"}}, wanted: []string{"aurelius", "seneca"}},
+		{name: "region falls back to its language", query: quotes.Query{Author: "Marcus Aurelius", Languages: []string{"de-AT"}}, wanted: []string{"aurelius-de"}},
+		{name: "no quote in the language", query: quotes.Query{Tag: "engineering", Languages: []string{"de"}}, wanted: []string{"pike"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			found := store.Find(tt.query)
+
+			// Assert
+			var ids []string
+			for _, q := range found {
+				ids = append(ids, q.ID)
+			}
+			assert.Equal(t, tt.wanted, ids)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// Quotes served to clients that solved their challenge
+package quotes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrNoQuotes     = errors.New("no quotes")
+	ErrInvalidQuote = errors.New("invalid quote")
+)
+
+// Quote - a single piece of wisdom
+type Quote struct {
+	// ID identifies the quote within its store
+	ID     string `json:"id" yaml:"id"`
+	Text   string `json:"text" yaml:"text"`
+	Author string `json:"author,omitempty" yaml:"author"`
+	// Source is the work the quote is taken from, e.g. a book or a speech
+	Source string   `json:"source,omitempty" yaml:"source"`
+	Tags   []string `json:"tags,omitempty" yaml:"tags"`
+	// Language is the BCP 47 tag of the text, e.g. "en", empty when unknown
+	Language string `json:"language,omitempty" yaml:"language"`
+	// Weight is how often StrategyWeighted serves the quote relative to the others, 0 counts as 1
+	Weight float64 `json:"weight,omitempty" yaml:"weight"`
+}
+
+// weight - the weight StrategyWeighted picks the quote with
+func (q Quote) weight() float64 {
+	if q.Weight == 0 {
+		return 1
+	}
+	return q.Weight
+}
+
+// Store - a corpus of quotes to serve
+type Store interface {
+	// All - returns every quote, the slice is shared between callers and must not be modified
+	All() []Quote
+	// Find - returns the quotes matching the query, looked up by index. The slice must not be modified.
+	Find(query Query) []Quote
+}
+
+type memoryStore struct {
+	quotes []Quote
+	index  index
+}
+
+// NewStore - creates a store holding the given quotes. Quotes without an ID are numbered by their position,
+// tags and languages are lower cased. Returns ErrNoQuotes for an empty corpus and ErrInvalidQuote for a quote without text,
+// with a duplicate ID or a negative weight.
+func NewStore(quotes ...Quote) (Store, error) {
+	normalized, err := normalize(quotes)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryStore{quotes: normalized, index: newIndex(normalized)}, nil
+}
+
+// All - returns every quote
+func (s *memoryStore) All() []Quote {
+	return s.quotes
+}
+
+// Find - returns the quotes matching the query
+func (s *memoryStore) Find(query Query) []Quote {
+	return s.index.find(s.quotes, query)
+}
+
+// normalize - validates the corpus and returns a copy of it with IDs and tags filled in consistently
+func normalize(quotes []Quote) ([]Quote, error) {
+	if len(quotes) == 0 {
+		return nil, ErrNoQuotes
+	}
+	normalized := make([]Quote, 0, len(quotes))
+	seen := make(map[string]bool, len(quotes))
+	for i, q := range quotes {
+		q.Text = strings.TrimSpace(q.Text)
+		if q.Text == "" {
+			return nil, fmt.Errorf("%w: quote %d has no text", ErrInvalidQuote, i+1)
+		}
+		if q.Weight < 0 {
+			return nil, fmt.Errorf("%w: quote %d has a negative weight", ErrInvalidQuote, i+1)
+		}
+		q.ID = strings.TrimSpace(q.ID)
+		if q.ID == "" {
+			q.ID = fmt.Sprint(i + 1)
+		}
+		if seen[q.ID] {
+			return nil, fmt.Errorf("%w: duplicate id %q", ErrInvalidQuote, q.ID)
+		}
+		seen[q.ID] = true
+		q.Author = strings.TrimSpace(q.Author)
+		q.Source = strings.TrimSpace(q.Source)
+		q.Language = strings.ToLower(strings.TrimSpace(q.Language))
+		var tags []string
+		for _, tag := range q.Tags {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		q.Tags = tags
+		normalized = append(normalized, q)
+	}
+	return normalized, nil
+}
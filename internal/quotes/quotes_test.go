@@ -0,0 +1,164 @@
+package quotes_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/quotes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEmbeddedStore(t *testing.T) {
+	// Act
+	all := quotes.NewEmbeddedStore().All()
+
+	// Assert
+	assert.Greater(t, len(all), 5)
+	for _, q := range all {
+		assert.NotEmpty(t, q.ID)
+		assert.NotEmpty(t, q.Text)
+		assert.NotEmpty(t, q.Author)
+		assert.NotEmpty(t, q.Tags)
+		assert.NotEmpty(t, q.Language)
+	}
+}
+
+func TestNewStoreValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		quotes    []quotes.Quote
+		wantedErr error
+	}{
+		{name: "empty corpus", wantedErr: quotes.ErrNoQuotes},
+		{name: "no text", quotes: []quotes.Quote{{ID: "1", Text: "  "}}, wantedErr: quotes.ErrInvalidQuote},
+		{name: "duplicate id", quotes: []quotes.Quote{{ID: "a", Text: "one"}, {ID: "a", Text: "two"}}, wantedErr: quotes.ErrInvalidQuote},
+		{name: "negative weight", quotes: []quotes.Quote{{Text: "one", Weight: -1}}, wantedErr: quotes.ErrInvalidQuote},
+		{name: "numbered ids", quotes: []quotes.Quote{{Text: "one"}, {Text: "two"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			store, err := quotes.NewStore(tt.quotes...)
+
+			// Assert
+			if tt.wantedErr != nil {
+				assert.True(t, errors.Is(err, tt.wantedErr))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "1", store.All()[0].ID)
+			assert.Equal(t, "2", store.All()[1].ID)
+		})
+	}
+}
+
+func TestNewFileStore(t *testing.T) {
+	wanted := quotes.Quote{
+		ID:       "seneca-imagination",
+		Text:     "We suffer more often in imagination than in reality.",
+		Author:   "Seneca",
+		Source:   "Letters to Lucilius",
+		Tags:     []string{"stoicism", "fear"},
+		Language: "en",
+		Weight:   2,
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "json",
+			file: "quotes.json",
+			content: `[{"id": "seneca-imagination", "text": "We suffer more often in imagination than in reality.",
+				"author": "Seneca", "source": "Letters to Lucilius", "tags": ["Stoicism", "fear"], "language": "EN", "weight": 2}]`,
+		},
+		{
+			name: "yaml",
+			file: "quotes.yaml",
+			content: `- id: seneca-imagination
+  text: We suffer more often in imagination than in reality.
+  author: Seneca
+  source: Letters to Lucilius
+  tags: [stoicism, fear]
+  language: en
+  weight: 2
+`,
+		},
+		{
+			name: "csv",
+			file: "quotes.csv",
+			content: `id,author,text,source,tags,language,weight
+seneca-imagination,Seneca,We suffer more often in imagination than in reality.,Letters to Lucilius,stoicism;fear,en,2
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			path := filepath.Join(t.TempDir(), tt.file)
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+
+			// Act
+			store, err := quotes.NewFileStore(path)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, []quotes.Quote{wanted}, store.All())
+		})
+	}
+}
+
+func TestNewFileStoreInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "broken json", file: "quotes.json", content: `[{"text": `},
+		{name: "empty yaml", file: "quotes.yaml", content: ``},
+		{name: "csv without text", file: "quotes.csv", content: "id,author\n1,Seneca\n"},
+		{name: "csv with invalid weight", file: "quotes.csv", content: "text,weight\none,heavy\n"},
+		{name: "unknown format", file: "quotes.xml", content: `<quotes/>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			path := filepath.Join(t.TempDir(), tt.file)
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+
+			// Act
+			_, err := quotes.NewFileStore(path)
+
+			// Assert
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewDirStore(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	files := map[string]string{
+		"pike-clear.txt": "Author: Rob Pike\nSource: Go Proverbs\nTags: engineering, simplicity\nLanguage: en\n\nClear is better than clever.\n",
+		"plain.txt":      "Note: a quote may contain colons, without header lines it's all text.",
+		"ignored.md":     "not a quote",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+
+	// Act
+	store, err := quotes.NewDirStore(dir)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []quotes.Quote{
+		{ID: "pike-clear", Text: "Clear is better than clever.", Author: "Rob Pike", Source: "Go Proverbs", Tags: []string{"engineering", "simplicity"}, Language: "en"},
+		{ID: "plain", Text: "Note: a quote may contain colons, without header lines it's all text."},
+	}, store.All())
+}
@@ -0,0 +1,158 @@
+package quotes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPollInterval - how often a watched corpus is checked for changes
+const DefaultPollInterval = 5 * time.Second
+
+// Load - loads the quotes at path, a json, yaml or csv file or a directory of text files
+func Load(path string) (Store, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return NewDirStore(path)
+	}
+	return NewFileStore(path)
+}
+
+// ReloadingStore - a store of the quotes at a path, which is replaced as a whole when the path is reloaded.
+// A corpus failing to load or validate never replaces the served one.
+type ReloadingStore struct {
+	path string
+	// current holds the served Store, swapped atomically so readers see either the old or the new corpus
+	current atomic.Value
+
+	// mu serializes reloads, version is the state of the path the current corpus was last attempted from
+	mu      sync.Mutex
+	version string
+}
+
+// NewReloadingStore - loads the quotes at path, failing when the initial corpus is invalid
+func NewReloadingStore(path string) (*ReloadingStore, error) {
+	s := &ReloadingStore{path: path}
+	version, err := versionOf(path)
+	if err != nil {
+		return nil, err
+	}
+	store, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	s.version = version
+	s.current.Store(store)
+	return s, nil
+}
+
+// All - returns every quote of the current corpus
+func (s *ReloadingStore) All() []Quote {
+	return s.current.Load().(Store).All()
+}
+
+// Find - returns the quotes of the current corpus matching the query
+func (s *ReloadingStore) Find(query Query) []Quote {
+	return s.current.Load().(Store).Find(query)
+}
+
+// Reload - loads the quotes at the path again and swaps them in. On error the current corpus is kept.
+func (s *ReloadingStore) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if version, err := versionOf(s.path); err == nil {
+		s.version = version
+	}
+	return s.reload()
+}
+
+// Watch - reloads the corpus every time the path changes, checked every interval (0 disables polling),
+// and every time a value is received on reload, e.g. SIGHUP, until the context is cancelled.
+// Errors are logged and the current corpus is kept.
+func (s *ReloadingStore) Watch(ctx context.Context, interval time.Duration, reload <-chan os.Signal) {
+	var poll <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+			if err := s.Reload(); err != nil {
+				log.Println("err reload quotes:", err)
+			}
+		case <-poll:
+			if err := s.reloadIfChanged(); err != nil {
+				log.Println("err reload quotes:", err)
+			}
+		}
+	}
+}
+
+// reloadIfChanged - reloads the corpus when the path changed since the last attempt. A broken corpus isn't
+// retried until it changes again, so an editor saving half a file gets it picked up once the save completed.
+func (s *ReloadingStore) reloadIfChanged() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	version, err := versionOf(s.path)
+	if err != nil {
+		return err
+	}
+	if version == s.version {
+		return nil
+	}
+	s.version = version
+	return s.reload()
+}
+
+func (s *ReloadingStore) reload() error {
+	store, err := Load(s.path)
+	if err != nil {
+		return err
+	}
+	s.current.Store(store)
+	log.Printf("reloaded %d quotes from %s", len(store.All()), s.path)
+	return nil
+}
+
+// versionOf - the size and modification time of the file, or of every quote file of the directory
+func versionOf(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return fileVersion(info), nil
+	}
+	paths, err := filepath.Glob(filepath.Join(path, "*.txt"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(paths)
+	versions := make([]string, 0, len(paths))
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return "", err
+		}
+		versions = append(versions, filepath.Base(p)+"@"+fileVersion(info))
+	}
+	return strings.Join(versions, ","), nil
+}
+
+func fileVersion(info os.FileInfo) string {
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+}
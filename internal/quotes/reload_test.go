@@ -0,0 +1,138 @@
+package quotes_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/quotes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeQuotes(t *testing.T, path string, content string) {
+	t.Helper()
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+func TestReloadingStoreReload(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wanted  []string
+		wantErr bool
+	}{
+		{name: "valid corpus is swapped in", content: `[{"text": "two"}, {"text": "three"}]`, wanted: []string{"two", "three"}},
+		{name: "broken file keeps the old corpus", content: `[{"text": `, wanted: []string{"one"}, wantErr: true},
+		{name: "invalid corpus keeps the old corpus", content: `[]`, wanted: []string{"one"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			path := filepath.Join(t.TempDir(), "quotes.json")
+			writeQuotes(t, path, `[{"text": "one"}]`)
+			store, err := quotes.NewReloadingStore(path)
+			assert.NoError(t, err)
+			writeQuotes(t, path, tt.content)
+
+			// Act
+			err = store.Reload()
+
+			// Assert
+			assert.Equal(t, tt.wantErr, err != nil)
+			var texts []string
+			for _, q := range store.All() {
+				texts = append(texts, q.Text)
+			}
+			assert.Equal(t, tt.wanted, texts)
+		})
+	}
+}
+
+func TestNewReloadingStoreInvalid(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "quotes.json")
+	writeQuotes(t, path, `[]`)
+
+	// Act
+	_, err := quotes.NewReloadingStore(path)
+
+	// Assert
+	assert.ErrorIs(t, err, quotes.ErrNoQuotes)
+}
+
+func TestReloadingStoreWatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		signal   bool
+	}{
+		{name: "polling", interval: 10 * time.Millisecond},
+		{name: "signal", signal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			dir := t.TempDir()
+			writeQuotes(t, filepath.Join(dir, "one.txt"), "one")
+			store, err := quotes.NewReloadingStore(dir)
+			assert.NoError(t, err)
+			reload := make(chan os.Signal, 1)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go store.Watch(ctx, tt.interval, reload)
+
+			// Act
+			writeQuotes(t, filepath.Join(dir, "two.txt"), "two")
+			if tt.signal {
+				reload <- os.Interrupt
+			}
+
+			// Assert
+			assert.Eventually(t, func() bool { return len(store.All()) == 2 }, time.Second, 5*time.Millisecond)
+		})
+	}
+}
+
+func TestReloadingStoreConcurrentReads(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "quotes.json")
+	writeQuotes(t, path, `[{"id": "a", "text": "one"}]`)
+	store, err := quotes.NewReloadingStore(path)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// a reader only ever sees one of the complete corpora
+				all := store.All()
+				assert.Contains(t, []int{1, 2}, len(all))
+				assert.Equal(t, "a", all[0].ID)
+			}
+		}()
+	}
+
+	// Act
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			writeQuotes(t, path, `[{"id": "a", "text": "one"}, {"id": "b", "text": "two"}]`)
+		} else {
+			writeQuotes(t, path, `[{"id": "a", "text": "one"}]`)
+		}
+		assert.NoError(t, store.Reload())
+	}
+	close(stop)
+	wg.Wait()
+}
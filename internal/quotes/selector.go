@@ -0,0 +1,228 @@
+package quotes
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Strategy - how the quote served to a client is picked out of the ones matching its request
+type Strategy string
+
+const (
+	// StrategyUniform - every quote is equally likely, a client may get the same quote twice in a row
+	StrategyUniform Strategy = "uniform"
+	// StrategyWeighted - quotes are picked in proportion to their Weight
+	StrategyWeighted Strategy = "weighted"
+	// StrategyShuffle - a client gets every quote once before any of them repeats
+	StrategyShuffle Strategy = "shuffle"
+	// StrategyDaily - every client gets the same quote all day, the day changes at midnight in the configured location
+	StrategyDaily Strategy = "daily"
+)
+
+// Selector - picks the quote served to a client
+type Selector interface {
+	// Select - picks one of the candidates, which are never empty, for the client identified by the key
+	Select(client string, candidates []Quote) Quote
+}
+
+// SelectorConfig - configuration of the selector created by NewSelector
+type SelectorConfig struct {
+	Strategy Strategy
+	// Rand is the source of randomness, seeded with the current time when nil. Tests pass a seeded one.
+	Rand *rand.Rand
+	// MaxClients is how many clients StrategyShuffle remembers the served quotes of, the least recently served is forgotten
+	MaxClients int
+	// Location is where the day of StrategyDaily starts and ends
+	Location *time.Location
+	// Now is the clock of StrategyDaily, time.Now when nil
+	Now func() time.Time
+}
+
+// DefaultSelectorConfig - returns the configuration used when none is provided
+func DefaultSelectorConfig() SelectorConfig {
+	return SelectorConfig{
+		Strategy:   StrategyUniform,
+		MaxClients: 10000,
+		Location:   time.UTC,
+	}
+}
+
+// NewSelector - creates the selector of the configured strategy
+func NewSelector(cfg SelectorConfig) (Selector, error) {
+	defaults := DefaultSelectorConfig()
+	if cfg.Rand == nil {
+		cfg.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if cfg.MaxClients <= 0 {
+		cfg.MaxClients = defaults.MaxClients
+	}
+	if cfg.Location == nil {
+		cfg.Location = defaults.Location
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	switch cfg.Strategy {
+	case "", StrategyUniform:
+		return NewUniformSelector(cfg.Rand), nil
+	case StrategyWeighted:
+		return NewWeightedSelector(cfg.Rand), nil
+	case StrategyShuffle:
+		return NewShuffleSelector(cfg.Rand, cfg.MaxClients), nil
+	case StrategyDaily:
+		return NewDailySelector(cfg.Location, cfg.Now), nil
+	default:
+		return nil, fmt.Errorf("unknown quote selection strategy %q", cfg.Strategy)
+	}
+}
+
+// lockedRand - a rand.Rand shared by concurrent requests
+type lockedRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (r *lockedRand) intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Intn(n)
+}
+
+func (r *lockedRand) float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Float64()
+}
+
+type uniformSelector struct {
+	rnd lockedRand
+}
+
+// NewUniformSelector - picks any of the candidates with the same probability
+func NewUniformSelector(rnd *rand.Rand) Selector {
+	return &uniformSelector{rnd: lockedRand{rnd: rnd}}
+}
+
+// Select - picks a random candidate
+func (s *uniformSelector) Select(_ string, candidates []Quote) Quote {
+	return candidates[s.rnd.intn(len(candidates))]
+}
+
+type weightedSelector struct {
+	rnd lockedRand
+}
+
+// NewWeightedSelector - picks the candidates in proportion to their Weight, quotes without one weigh 1
+func NewWeightedSelector(rnd *rand.Rand) Selector {
+	return &weightedSelector{rnd: lockedRand{rnd: rnd}}
+}
+
+// Select - picks a random candidate, heavier ones more often
+func (s *weightedSelector) Select(_ string, candidates []Quote) Quote {
+	var total float64
+	for _, q := range candidates {
+		total += q.weight()
+	}
+	target := s.rnd.float64() * total
+	for _, q := range candidates {
+		if target -= q.weight(); target < 0 {
+			return q
+		}
+	}
+	// rounding left a sliver past the last quote
+	return candidates[len(candidates)-1]
+}
+
+type shuffleSelector struct {
+	rnd        lockedRand
+	maxClients int
+
+	mu sync.Mutex
+	// bags are the clients' served quotes, the front of recent is the client served last
+	bags   map[string]*list.Element
+	recent *list.List
+}
+
+// bag - the quotes served to a client since its bag was last refilled
+type bag struct {
+	client string
+	served map[string]bool
+	last   string
+}
+
+// NewShuffleSelector - serves a client every candidate once, in random order, before any of them is served again.
+// The served quotes of up to maxClients clients are remembered.
+func NewShuffleSelector(rnd *rand.Rand, maxClients int) Selector {
+	return &shuffleSelector{
+		rnd:        lockedRand{rnd: rnd},
+		maxClients: maxClients,
+		bags:       map[string]*list.Element{},
+		recent:     list.New(),
+	}
+}
+
+// Select - picks a random candidate the client wasn't served yet, refilling the bag once it got all of them
+func (s *shuffleSelector) Select(client string, candidates []Quote) Quote {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.bag(client)
+	remaining := make([]Quote, 0, len(candidates))
+	for _, q := range candidates {
+		if !b.served[q.ID] {
+			remaining = append(remaining, q)
+		}
+	}
+	if len(remaining) == 0 {
+		b.served = map[string]bool{}
+		// the refilled bag mustn't start with the quote the last one ended with
+		for _, q := range candidates {
+			if q.ID != b.last || len(candidates) == 1 {
+				remaining = append(remaining, q)
+			}
+		}
+	}
+
+	q := remaining[s.rnd.intn(len(remaining))]
+	b.served[q.ID] = true
+	b.last = q.ID
+	return q
+}
+
+// bag - returns the client's bag, creating it and forgetting the least recently served client when needed
+func (s *shuffleSelector) bag(client string) *bag {
+	if e, ok := s.bags[client]; ok {
+		s.recent.MoveToFront(e)
+		return e.Value.(*bag)
+	}
+	if s.recent.Len() >= s.maxClients {
+		oldest := s.recent.Back()
+		s.recent.Remove(oldest)
+		delete(s.bags, oldest.Value.(*bag).client)
+	}
+	b := &bag{client: client, served: map[string]bool{}}
+	s.bags[client] = s.recent.PushFront(b)
+	return b
+}
+
+type dailySelector struct {
+	location *time.Location
+	now      func() time.Time
+}
+
+// NewDailySelector - serves every client the same candidate for the whole day in the given location
+func NewDailySelector(location *time.Location, now func() time.Time) Selector {
+	return &dailySelector{location: location, now: now}
+}
+
+// Select - picks the candidate of the current day, the same one for every client and server with the same corpus
+func (s *dailySelector) Select(_ string, candidates []Quote) Quote {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s.now().In(s.location).Format("2006-01-02")))
+	return candidates[h.Sum64()%uint64(len(candidates))]
+}
@@ -0,0 +1,156 @@
+package quotes_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/quotes"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testCandidates(n int) []quotes.Quote {
+	candidates := make([]quotes.Quote, n)
+	for i := range candidates {
+		candidates[i] = quotes.Quote{ID: string(rune('a' + i)), Text: "quote"}
+	}
+	return candidates
+}
+
+func TestNewSelector(t *testing.T) {
+	tests := []struct {
+		strategy quotes.Strategy
+		wantErr  bool
+	}{
+		{strategy: ""},
+		{strategy: quotes.StrategyUniform},
+		{strategy: quotes.StrategyWeighted},
+		{strategy: quotes.StrategyShuffle},
+		{strategy: quotes.StrategyDaily},
+		{strategy: "alphabetical", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			// Arrange
+			cfg := quotes.DefaultSelectorConfig()
+			cfg.Strategy = tt.strategy
+
+			// Act
+			selector, err := quotes.NewSelector(cfg)
+
+			// Assert
+			assert.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Contains(t, testCandidates(3), selector.Select("client", testCandidates(3)))
+			}
+		})
+	}
+}
+
+func TestUniformSelectorSeeded(t *testing.T) {
+	// Arrange
+	first := quotes.NewUniformSelector(rand.New(rand.NewSource(42)))
+	second := quotes.NewUniformSelector(rand.New(rand.NewSource(42)))
+	candidates := testCandidates(10)
+
+	// Act & Assert
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, first.Select("client", candidates), second.Select("other client", candidates))
+	}
+}
+
+func TestWeightedSelector(t *testing.T) {
+	// Arrange
+	selector := quotes.NewWeightedSelector(rand.New(rand.NewSource(42)))
+	candidates := []quotes.Quote{{ID: "heavy", Weight: 9}, {ID: "light"}}
+
+	// Act
+	served := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		served[selector.Select("client", candidates).ID]++
+	}
+
+	// Assert
+	assert.InDelta(t, 900, served["heavy"], 50)
+	assert.InDelta(t, 100, served["light"], 50)
+}
+
+func TestShuffleSelector(t *testing.T) {
+	// Arrange
+	selector := quotes.NewShuffleSelector(rand.New(rand.NewSource(42)), 10)
+	candidates := testCandidates(5)
+
+	// Act
+	var served []string
+	for i := 0; i < 4*len(candidates); i++ {
+		served = append(served, selector.Select("client", candidates).ID)
+		// another client doesn't use up the bag
+		selector.Select("other client", candidates)
+	}
+
+	// Assert
+	for round := 0; round < 4; round++ {
+		bag := served[round*len(candidates) : (round+1)*len(candidates)]
+		assert.ElementsMatch(t, []string{"a", "b", "c", "d", "e"}, bag)
+	}
+	for i := 1; i < len(served); i++ {
+		assert.NotEqual(t, served[i-1], served[i])
+	}
+}
+
+func TestShuffleSelectorMaxClients(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxClients  int
+		wantRepeats bool
+	}{
+		{name: "client remembered", maxClients: 2},
+		{name: "client forgotten", maxClients: 1, wantRepeats: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			repeats := 0
+			for seed := int64(0); seed < 20; seed++ {
+				selector := quotes.NewShuffleSelector(rand.New(rand.NewSource(seed)), tt.maxClients)
+				first := selector.Select("client", testCandidates(2))
+				selector.Select("other client", testCandidates(2))
+				if selector.Select("client", testCandidates(2)).ID == first.ID {
+					repeats++
+				}
+			}
+
+			// Assert
+			assert.Equal(t, tt.wantRepeats, repeats > 0)
+		})
+	}
+}
+
+func TestDailySelector(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	// 20:00 UTC on the 1st is already the 2nd in Tokyo
+	evening := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	candidates := testCandidates(30)
+	selectAt := func(location *time.Location, now time.Time) quotes.Quote {
+		return quotes.NewDailySelector(location, func() time.Time { return now }).Select("client", candidates)
+	}
+
+	tests := []struct {
+		name     string
+		first    quotes.Quote
+		second   quotes.Quote
+		wantSame bool
+	}{
+		{name: "same day", first: selectAt(time.UTC, evening), second: selectAt(time.UTC, evening.Add(-20*time.Hour)), wantSame: true},
+		{name: "same local day", first: selectAt(tokyo, evening), second: selectAt(time.UTC, evening.Add(24*time.Hour)), wantSame: true},
+		{name: "next day", first: selectAt(time.UTC, evening), second: selectAt(time.UTC, evening.Add(24*time.Hour))},
+		{name: "another local day", first: selectAt(time.UTC, evening), second: selectAt(tokyo, evening)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Assert
+			assert.Equal(t, tt.wantSame, tt.first.ID == tt.second.ID)
+		})
+	}
+}
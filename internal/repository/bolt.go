@@ -0,0 +1,386 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// indicatorsBucket maps an indicator to its boltItem
+	indicatorsBucket = []byte("indicators")
+	// expiryBucket keys are the big endian expiry time followed by the indicator, so a cursor walks them soonest first
+	expiryBucket = []byte("expiry")
+	// rename swaps the compacted file in, a variable so tests can make it fail
+	rename = os.Rename
+)
+
+// BoltConfig - configures the bolt repository
+type BoltConfig struct {
+	// Path of the database file, created when missing
+	Path string
+	// TTL is how long indicators are kept unless their Entry sets its own TTL
+	TTL time.Duration
+	// SweepInterval is how often the janitor removes expired indicators
+	SweepInterval time.Duration
+	// CompactInterval is how often the janitor rewrites the file to give the space of removed indicators back
+	CompactInterval time.Duration
+}
+
+// DefaultBoltConfig - indicators.db in the working directory, indicators live for 10 minutes like in memory
+func DefaultBoltConfig() BoltConfig {
+	return BoltConfig{
+		Path:            "indicators.db",
+		TTL:             DefaultConfig().TTL,
+		SweepInterval:   time.Minute,
+		CompactInterval: time.Hour,
+	}
+}
+
+type boltItem struct {
+	Entry     Entry     `json:"entry"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type boltDB struct {
+	// expired counts swept indicators, updated atomically so it comes first to stay 64-bit aligned
+	expired uint64
+	cfg     BoltConfig
+	// rw guards db, which compaction swaps for a rewritten file, and err
+	rw *sync.RWMutex
+	db *bolt.DB
+	// err is set when compaction couldn't reopen the file, every call returns it from then on
+	err error
+}
+
+// NewBoltDB - opens a file backed repository, so issued challenges survive restarts of a single node.
+// Every change is committed to disk before it returns. Zero config values fall back to DefaultBoltConfig.
+func NewBoltDB(cfg BoltConfig) (Repository, error) {
+	defaults := DefaultBoltConfig()
+	if cfg.Path == "" {
+		cfg.Path = defaults.Path
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaults.TTL
+	}
+	if cfg.SweepInterval <= 0 {
+		cfg.SweepInterval = defaults.SweepInterval
+	}
+	if cfg.CompactInterval <= 0 {
+		cfg.CompactInterval = defaults.CompactInterval
+	}
+
+	db, err := openBolt(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &boltDB{cfg: cfg, rw: &sync.RWMutex{}, db: db}, nil
+}
+
+func openBolt(path string) (*bolt.DB, error) {
+	// the timeout keeps a second process from waiting on the file lock forever
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("err open %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(indicatorsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(expiryBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// AddIndicator - adds indicator to the file, unless it's already there.
+// Bolt serializes write transactions, so of concurrent calls with the same indicator only one succeeds
+func (r *boltDB) AddIndicator(ctx context.Context, indicator string, entry Entry) error {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	now := time.Now()
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+	ttl := entry.TTL
+	if ttl <= 0 {
+		ttl = r.cfg.TTL
+	}
+	item := boltItem{Entry: entry, ExpiresAt: entry.CreatedAt.Add(ttl)}
+	value, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		existing, err := getBoltItem(tx, indicator)
+		if err == nil && now.Before(existing.ExpiresAt) {
+			return ErrIndicatorExists
+		}
+		if err == nil {
+			// expired but not swept yet
+			if err := deleteBoltItem(tx, indicator, existing); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(indicatorsBucket).Put([]byte(indicator), value); err != nil {
+			return err
+		}
+		return tx.Bucket(expiryBucket).Put(expiryKey(item.ExpiresAt, indicator), nil)
+	})
+}
+
+// GetIndicator - returns indicator's entry from the file
+func (r *boltDB) GetIndicator(ctx context.Context, indicator string) (Entry, error) {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+	if r.err != nil {
+		return Entry{}, r.err
+	}
+
+	var item boltItem
+	err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+		item, err = getBoltItem(tx, indicator)
+		return err
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	// the janitor removes it eventually
+	if !time.Now().Before(item.ExpiresAt) {
+		return Entry{}, ErrIndicatorExpired
+	}
+	return item.Entry, nil
+}
+
+// ConsumeIndicator - returns indicator's entry and removes it in the same transaction
+func (r *boltDB) ConsumeIndicator(ctx context.Context, indicator string) (Entry, error) {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+	if r.err != nil {
+		return Entry{}, r.err
+	}
+
+	var item boltItem
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		item, err = getBoltItem(tx, indicator)
+		if err != nil {
+			return err
+		}
+		return deleteBoltItem(tx, indicator, item)
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	if !time.Now().Before(item.ExpiresAt) {
+		atomic.AddUint64(&r.expired, 1)
+		return Entry{}, ErrIndicatorExpired
+	}
+	return item.Entry, nil
+}
+
+// RemoveIndicator - removes indicator from the file
+func (r *boltDB) RemoveIndicator(ctx context.Context, indicator string) {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+	if r.err != nil {
+		return
+	}
+
+	_ = r.db.Update(func(tx *bolt.Tx) error {
+		item, err := getBoltItem(tx, indicator)
+		if err != nil {
+			return err
+		}
+		return deleteBoltItem(tx, indicator, item)
+	})
+}
+
+// RunJanitor - removes expired indicators every SweepInterval and compacts the file every CompactInterval,
+// until the context is cancelled
+func (r *boltDB) RunJanitor(ctx context.Context) {
+	sweep := time.NewTicker(r.cfg.SweepInterval)
+	defer sweep.Stop()
+	compact := time.NewTicker(r.cfg.CompactInterval)
+	defer compact.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-sweep.C:
+			if err := r.sweep(now); err != nil {
+				fmt.Println("err sweep indicators:", err)
+			}
+		case <-compact.C:
+			if err := r.Compact(); err != nil {
+				fmt.Println("err compact indicators:", err)
+			}
+		}
+	}
+}
+
+// Stats - returns the count of stored and swept indicators, nothing is ever evicted or rejected
+func (r *boltDB) Stats() Stats {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+
+	stats := Stats{Expired: atomic.LoadUint64(&r.expired)}
+	if r.err != nil {
+		return stats
+	}
+	_ = r.db.View(func(tx *bolt.Tx) error {
+		stats.Size = tx.Bucket(indicatorsBucket).Stats().KeyN
+		return nil
+	})
+	return stats
+}
+
+// Compact - rewrites the live indicators into a fresh file and swaps it in.
+// Bolt never shrinks its file, so this is the only way to give the space of removed indicators back.
+// The original file is only replaced by an atomic rename once the copy is complete.
+// When the file can't be reopened afterwards the repository is unusable and every call returns ErrRepositoryUnusable.
+func (r *boltDB) Compact() error {
+	r.rw.Lock()
+	defer r.rw.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	tmpPath := r.cfg.Path + ".compact"
+	// a leftover of a compaction that crashed
+	_ = os.Remove(tmpPath)
+	dst, err := openBolt(tmpPath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = r.db.View(func(src *bolt.Tx) error {
+		return dst.Update(func(tx *bolt.Tx) error {
+			return src.Bucket(indicatorsBucket).ForEach(func(k, v []byte) error {
+				var item boltItem
+				if err := json.Unmarshal(v, &item); err != nil {
+					return err
+				}
+				if !now.Before(item.ExpiresAt) {
+					return nil
+				}
+				if err := tx.Bucket(indicatorsBucket).Put(k, v); err != nil {
+					return err
+				}
+				return tx.Bucket(expiryBucket).Put(expiryKey(item.ExpiresAt, string(k)), nil)
+			})
+		})
+	})
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("err copy indicators: %w", err)
+	}
+
+	if err := r.db.Close(); err != nil {
+		// bolt marks the file closed even when closing fails
+		_ = os.Remove(tmpPath)
+		r.err = ErrRepositoryUnusable
+		return fmt.Errorf("%w: err close indicators: %v", ErrRepositoryUnusable, err)
+	}
+	renameErr := rename(tmpPath, r.cfg.Path)
+	if renameErr != nil {
+		_ = os.Remove(tmpPath)
+	}
+	// after a failed rename this is the original file, so serving goes on uncompacted
+	db, err := openBolt(r.cfg.Path)
+	if err != nil {
+		r.err = ErrRepositoryUnusable
+		return fmt.Errorf("%w: err reopen indicators: %v", ErrRepositoryUnusable, err)
+	}
+	r.db = db
+	if renameErr != nil {
+		return fmt.Errorf("err swap compacted indicators: %w", renameErr)
+	}
+	return nil
+}
+
+// Close - closes the file
+func (r *boltDB) Close() error {
+	r.rw.Lock()
+	defer r.rw.Unlock()
+	if r.err != nil {
+		// compaction already closed the file
+		return nil
+	}
+	return r.db.Close()
+}
+
+// sweep - walks the expiry index up to now and removes what it finds
+func (r *boltDB) sweep(now time.Time) error {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		indicators, expiry := tx.Bucket(indicatorsBucket), tx.Bucket(expiryBucket)
+		// collected first, deleting under a cursor makes it skip keys
+		var expired [][]byte
+		limit := expiryKey(now, "")
+		cursor := expiry.Cursor()
+		for k, _ := cursor.First(); k != nil && bytes.Compare(k, limit) < 0; k, _ = cursor.Next() {
+			expired = append(expired, k)
+		}
+		for _, k := range expired {
+			if err := indicators.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := expiry.Delete(k); err != nil {
+				return err
+			}
+		}
+		atomic.AddUint64(&r.expired, uint64(len(expired)))
+		return nil
+	})
+}
+
+func getBoltItem(tx *bolt.Tx, indicator string) (boltItem, error) {
+	var item boltItem
+	value := tx.Bucket(indicatorsBucket).Get([]byte(indicator))
+	if value == nil {
+		return item, ErrIndicatorNotFound
+	}
+	err := json.Unmarshal(value, &item)
+	return item, err
+}
+
+func deleteBoltItem(tx *bolt.Tx, indicator string, item boltItem) error {
+	if err := tx.Bucket(indicatorsBucket).Delete([]byte(indicator)); err != nil {
+		return err
+	}
+	return tx.Bucket(expiryBucket).Delete(expiryKey(item.ExpiresAt, indicator))
+}
+
+func expiryKey(expiresAt time.Time, indicator string) []byte {
+	key := make([]byte, 8+len(indicator))
+	binary.BigEndian.PutUint64(key, uint64(expiresAt.UnixNano()))
+	copy(key[8:], indicator)
+	return key
+}
@@ -0,0 +1,199 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// boltRepository - the methods of the bolt repository beyond repository.Repository
+type boltRepository interface {
+	repository.Repository
+	repository.Janitor
+	repository.StatsReporter
+	Compact() error
+	Close() error
+}
+
+func newBoltDB(t *testing.T, cfg repository.BoltConfig) boltRepository {
+	repo, err := repository.NewBoltDB(cfg)
+	assert.NoError(t, err)
+	return repo.(boltRepository)
+}
+
+func TestBoltDB(t *testing.T) {
+	// Arrange
+	repo := newBoltDB(t, repository.BoltConfig{Path: filepath.Join(t.TempDir(), "indicators.db")})
+	defer repo.Close()
+	ctx := context.Background()
+
+	// Act
+	addErr := repo.AddIndicator(ctx, "123456789", repository.Entry{Difficulty: 5, Version: 2})
+	existsErr := repo.AddIndicator(ctx, "123456789", repository.Entry{Difficulty: 1})
+	entry, getErr := repo.GetIndicator(ctx, "123456789")
+	repo.RemoveIndicator(ctx, "123456789")
+	_, removedErr := repo.GetIndicator(ctx, "123456789")
+
+	// Assert
+	assert.NoError(t, addErr)
+	assert.Equal(t, repository.ErrIndicatorExists, existsErr)
+	assert.NoError(t, getErr)
+	assert.Equal(t, 5, entry.Difficulty)
+	assert.Equal(t, 2, entry.Version)
+	assert.Equal(t, repository.ErrIndicatorNotFound, removedErr)
+}
+
+func TestBoltDBSurvivesRestart(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "indicators.db")
+	before := newBoltDB(t, repository.BoltConfig{Path: path})
+	assert.NoError(t, before.AddIndicator(context.Background(), "123456789", repository.Entry{Difficulty: 5}))
+	assert.NoError(t, before.Close())
+
+	// Act
+	after := newBoltDB(t, repository.BoltConfig{Path: path})
+	defer after.Close()
+	entry, err := after.GetIndicator(context.Background(), "123456789")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 5, entry.Difficulty)
+}
+
+func TestBoltDBExpiry(t *testing.T) {
+	// Arrange
+	repo := newBoltDB(t, repository.BoltConfig{
+		Path:          filepath.Join(t.TempDir(), "indicators.db"),
+		TTL:           10 * time.Millisecond,
+		SweepInterval: 5 * time.Millisecond,
+	})
+	defer repo.Close()
+	assert.NoError(t, repo.AddIndicator(context.Background(), "expiring", repository.Entry{}))
+	assert.NoError(t, repo.AddIndicator(context.Background(), "kept", repository.Entry{TTL: time.Hour}))
+	time.Sleep(20 * time.Millisecond)
+	_, expiredErr := repo.GetIndicator(context.Background(), "expiring")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	// Act
+	go func() {
+		repo.RunJanitor(ctx)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// Assert
+	<-done
+	assert.Equal(t, repository.ErrIndicatorExpired, expiredErr)
+	stats := repo.Stats()
+	assert.Equal(t, 1, stats.Size)
+	assert.Equal(t, uint64(1), stats.Expired)
+	_, err := repo.GetIndicator(context.Background(), "kept")
+	assert.NoError(t, err)
+}
+
+func TestBoltDBCompact(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "indicators.db")
+	repo := newBoltDB(t, repository.BoltConfig{Path: path})
+	defer repo.Close()
+	ctx := context.Background()
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, repo.AddIndicator(ctx, strconv.Itoa(i), repository.Entry{Difficulty: 5}))
+	}
+	for i := 1; i < 1000; i++ {
+		repo.RemoveIndicator(ctx, strconv.Itoa(i))
+	}
+	before, err := os.Stat(path)
+	assert.NoError(t, err)
+
+	// Act
+	err = repo.Compact()
+
+	// Assert
+	assert.NoError(t, err)
+	after, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Less(t, after.Size(), before.Size())
+	entry, err := repo.GetIndicator(ctx, "0")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, entry.Difficulty)
+	// the swapped in file is writable
+	assert.NoError(t, repo.AddIndicator(ctx, "new", repository.Entry{}))
+}
+
+func TestBoltDBCompactFailure(t *testing.T) {
+	errRename := errors.New("rename failed")
+	tests := []struct {
+		name string
+		// rename replaces the rename of the compacted file over the original
+		rename      func(oldpath, newpath string) error
+		wantedErr   error
+		usableAfter bool
+	}{
+		{
+			name: "rename fails, the original file is reopened",
+			rename: func(oldpath, newpath string) error {
+				return errRename
+			},
+			wantedErr:   errRename,
+			usableAfter: true,
+		},
+		{
+			name: "reopen fails, the repository is unusable",
+			rename: func(oldpath, newpath string) error {
+				if err := os.Rename(oldpath, newpath); err != nil {
+					return err
+				}
+				if err := os.Remove(newpath); err != nil {
+					return err
+				}
+				// a directory can't be opened as a bolt file
+				return os.Mkdir(newpath, 0700)
+			},
+			wantedErr:   repository.ErrRepositoryUnusable,
+			usableAfter: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repository.SetRename(t, tt.rename)
+			path := filepath.Join(t.TempDir(), "indicators.db")
+			repo := newBoltDB(t, repository.BoltConfig{Path: path})
+			defer repo.Close()
+			ctx := context.Background()
+			assert.NoError(t, repo.AddIndicator(ctx, "123456789", repository.Entry{Difficulty: 5}))
+
+			// Act
+			err := repo.Compact()
+
+			// Assert
+			assert.ErrorIs(t, err, tt.wantedErr)
+			_, statErr := os.Stat(path + ".compact")
+			assert.True(t, os.IsNotExist(statErr))
+			entry, getErr := repo.GetIndicator(ctx, "123456789")
+			addErr := repo.AddIndicator(ctx, "new", repository.Entry{})
+			if tt.usableAfter {
+				assert.NoError(t, getErr)
+				assert.Equal(t, 5, entry.Difficulty)
+				assert.NoError(t, addErr)
+				return
+			}
+			assert.Equal(t, repository.ErrRepositoryUnusable, getErr)
+			assert.Equal(t, repository.ErrRepositoryUnusable, addErr)
+			assert.Equal(t, repository.ErrRepositoryUnusable, repo.Compact())
+			assert.Equal(t, 0, repo.Stats().Size)
+			assert.NotPanics(t, func() { repo.RemoveIndicator(ctx, "123456789") })
+		})
+	}
+}
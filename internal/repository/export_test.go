@@ -0,0 +1,10 @@
+package repository
+
+import "testing"
+
+// SetRename - replaces the rename compaction swaps files with until the test ends
+func SetRename(t *testing.T, fn func(oldpath, newpath string) error) {
+	original := rename
+	rename = fn
+	t.Cleanup(func() { rename = original })
+}
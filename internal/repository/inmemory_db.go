@@ -1,60 +1,218 @@
-// Used to track random generated numbers used in hashcash.Stamps to prevent replay attacks
 package repository
 
 import (
+	"container/list"
 	"context"
-	"errors"
 	"sync"
 	"time"
 )
 
-var (
-	ErrIndicatorNotFound = errors.New("indicator not existing in inmemry db")
+// EvictionPolicy - what happens when an indicator is added to a full repository
+type EvictionPolicy int
+
+const (
+	// EvictOldest - drops the indicator that was added first to make room
+	EvictOldest EvictionPolicy = iota
+	// RejectNew - refuses new indicators with ErrCapacityExceeded until old ones expire or are removed
+	RejectNew
 )
 
-type Repository interface {
-	AddIndicator(ctx context.Context, indicator int64) error
-	GetIndicator(ctx context.Context, indicator int64) (int64, error)
-	RemoveIndicator(ctx context.Context, indicator int64)
+// Config - configures the in-memory repository
+type Config struct {
+	// TTL is how long indicators are kept unless their Entry sets its own TTL
+	TTL time.Duration
+	// Capacity is the maximum count of stored indicators
+	Capacity int
+	// Eviction is applied once Capacity is reached
+	Eviction EvictionPolicy
+	// SweepInterval is how often the janitor removes expired indicators
+	SweepInterval time.Duration
+}
+
+// DefaultConfig - indicators live for 10 minutes, at most 100000 of them with the oldest evicted first
+func DefaultConfig() Config {
+	return Config{
+		TTL:           10 * time.Minute,
+		Capacity:      100000,
+		Eviction:      EvictOldest,
+		SweepInterval: time.Minute,
+	}
+}
+
+type inMemoryItem struct {
+	indicator string
+	entry     Entry
+	expiresAt time.Time
 }
 
 type inMemoryDB struct {
-	hashcashIndicators map[int64]time.Time
+	cfg Config
+	// hashcashIndicators points into order, which keeps the indicators oldest first
+	hashcashIndicators map[string]*list.Element
+	order              *list.List
+	stats              Stats
 	rw                 *sync.RWMutex
 }
 
-// NewInMemoryDB ..
+// NewInMemoryDB - creates an in-memory repository with DefaultConfig
 func NewInMemoryDB() Repository {
-	return &inMemoryDB{hashcashIndicators: map[int64]time.Time{}, rw: &sync.RWMutex{}}
+	return NewInMemoryDBWithConfig(DefaultConfig())
+}
+
+// NewInMemoryDBWithConfig - creates an in-memory repository, zero config values fall back to DefaultConfig.
+// Expired indicators are only swept while RunJanitor runs.
+func NewInMemoryDBWithConfig(cfg Config) Repository {
+	defaults := DefaultConfig()
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaults.TTL
+	}
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = defaults.Capacity
+	}
+	if cfg.SweepInterval <= 0 {
+		cfg.SweepInterval = defaults.SweepInterval
+	}
+	return &inMemoryDB{
+		cfg:                cfg,
+		hashcashIndicators: map[string]*list.Element{},
+		order:              list.New(),
+		rw:                 &sync.RWMutex{},
+	}
 }
 
-// AddIndicator - adds indicator to inmemorydb
-func (r *inMemoryDB) AddIndicator(ctx context.Context, indicator int64) error {
+// AddIndicator - adds indicator to inmemorydb, unless it's already there.
+// Checking and adding happen under one lock, so of concurrent calls with the same indicator only one succeeds
+func (r *inMemoryDB) AddIndicator(ctx context.Context, indicator string, entry Entry) error {
 	r.rw.Lock()
 	defer r.rw.Unlock()
 
-	r.hashcashIndicators[indicator] = time.Now()
+	now := time.Now()
+	if el, ok := r.hashcashIndicators[indicator]; ok {
+		if !r.expired(el, now) {
+			return ErrIndicatorExists
+		}
+		r.remove(el)
+		r.stats.Expired++
+	}
+
+	if len(r.hashcashIndicators) >= r.cfg.Capacity {
+		oldest := r.order.Front()
+		switch {
+		case r.expired(oldest, now):
+			r.remove(oldest)
+			r.stats.Expired++
+		case r.cfg.Eviction == RejectNew:
+			r.stats.Rejected++
+			return ErrCapacityExceeded
+		default:
+			r.remove(oldest)
+			r.stats.Evicted++
+		}
+	}
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+	ttl := entry.TTL
+	if ttl <= 0 {
+		ttl = r.cfg.TTL
+	}
+	r.hashcashIndicators[indicator] = r.order.PushBack(&inMemoryItem{
+		indicator: indicator,
+		entry:     entry,
+		expiresAt: entry.CreatedAt.Add(ttl),
+	})
 
 	return nil
 }
 
-// GetIndicator - returns indicator from db
-func (r *inMemoryDB) GetIndicator(ctx context.Context, requestedIndicator int64) (int64, error) {
+// GetIndicator - returns indicator's entry from db
+func (r *inMemoryDB) GetIndicator(ctx context.Context, requestedIndicator string) (Entry, error) {
 	r.rw.RLock()
 	defer r.rw.RUnlock()
 
-	_, ok := r.hashcashIndicators[requestedIndicator]
-	if ok {
-		return requestedIndicator, nil
+	el, ok := r.hashcashIndicators[requestedIndicator]
+	if !ok {
+		return Entry{}, ErrIndicatorNotFound
+	}
+	// the janitor removes it eventually
+	if r.expired(el, time.Now()) {
+		return Entry{}, ErrIndicatorExpired
 	}
+	return el.Value.(*inMemoryItem).entry, nil
+}
+
+// ConsumeIndicator - returns indicator's entry and removes it under one lock
+func (r *inMemoryDB) ConsumeIndicator(ctx context.Context, indicator string) (Entry, error) {
+	r.rw.Lock()
+	defer r.rw.Unlock()
 
-	return 0, ErrIndicatorNotFound
+	el, ok := r.hashcashIndicators[indicator]
+	if !ok {
+		return Entry{}, ErrIndicatorNotFound
+	}
+	r.remove(el)
+	if r.expired(el, time.Now()) {
+		r.stats.Expired++
+		return Entry{}, ErrIndicatorExpired
+	}
+	return el.Value.(*inMemoryItem).entry, nil
 }
 
 // RemoveIndicator - removes indicator from db
-func (r *inMemoryDB) RemoveIndicator(ctx context.Context, newIndicator int64) {
+func (r *inMemoryDB) RemoveIndicator(ctx context.Context, newIndicator string) {
+	r.rw.Lock()
+	defer r.rw.Unlock()
+
+	if el, ok := r.hashcashIndicators[newIndicator]; ok {
+		r.remove(el)
+	}
+}
+
+// RunJanitor - removes expired indicators every SweepInterval until the context is cancelled
+func (r *inMemoryDB) RunJanitor(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.sweep(now)
+		}
+	}
+}
+
+// Stats - returns the eviction counters
+func (r *inMemoryDB) Stats() Stats {
+	r.rw.RLock()
+	defer r.rw.RUnlock()
+
+	stats := r.stats
+	stats.Size = len(r.hashcashIndicators)
+	return stats
+}
+
+// sweep - entries can have different TTLs, so every one of them has to be checked
+func (r *inMemoryDB) sweep(now time.Time) {
 	r.rw.Lock()
 	defer r.rw.Unlock()
 
-	delete(r.hashcashIndicators, newIndicator)
+	for el := r.order.Front(); el != nil; {
+		next := el.Next()
+		if r.expired(el, now) {
+			r.remove(el)
+			r.stats.Expired++
+		}
+		el = next
+	}
+}
+
+func (r *inMemoryDB) expired(el *list.Element, now time.Time) bool {
+	return !now.Before(el.Value.(*inMemoryItem).expiresAt)
+}
+
+func (r *inMemoryDB) remove(el *list.Element) {
+	delete(r.hashcashIndicators, el.Value.(*inMemoryItem).indicator)
+	r.order.Remove(el)
 }
@@ -2,7 +2,10 @@ package repository_test
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Lockwarr/WordOfWisdom/internal/repository"
 
@@ -14,7 +17,7 @@ func TestAddIndicator(t *testing.T) {
 	repo := repository.NewInMemoryDB()
 
 	// Act
-	err := repo.AddIndicator(context.Background(), 123456789)
+	err := repo.AddIndicator(context.Background(), "123456789", repository.Entry{Difficulty: 5})
 
 	// Assert
 	assert.Nil(t, err)
@@ -25,13 +28,14 @@ func TestGetIndicator(t *testing.T) {
 	repo := repository.NewInMemoryDB()
 
 	// Act
-	err := repo.AddIndicator(context.Background(), 123456789)
+	err := repo.AddIndicator(context.Background(), "123456789", repository.Entry{Difficulty: 5})
 	assert.NoError(t, err)
 
-	indicator, err := repo.GetIndicator(context.Background(), 123456789)
+	entry, err := repo.GetIndicator(context.Background(), "123456789")
 	// Assert
 	assert.Nil(t, err)
-	assert.Equal(t, int64(123456789), indicator)
+	assert.Equal(t, 5, entry.Difficulty)
+	assert.False(t, entry.CreatedAt.IsZero())
 }
 
 func TestRemoveIndicator(t *testing.T) {
@@ -39,11 +43,146 @@ func TestRemoveIndicator(t *testing.T) {
 	repo := repository.NewInMemoryDB()
 
 	// Act
-	err := repo.AddIndicator(context.Background(), 123456789)
+	err := repo.AddIndicator(context.Background(), "123456789", repository.Entry{Difficulty: 5})
 	assert.NoError(t, err)
-	repo.RemoveIndicator(context.Background(), 123456789)
-	_, err = repo.GetIndicator(context.Background(), 123456789)
+	repo.RemoveIndicator(context.Background(), "123456789")
+	_, err = repo.GetIndicator(context.Background(), "123456789")
 
 	// Assert
 	assert.NotNil(t, err)
 }
+
+func TestAddExistingIndicator(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDB()
+	err := repo.AddIndicator(context.Background(), "123456789", repository.Entry{Difficulty: 5})
+	assert.NoError(t, err)
+
+	// Act
+	err = repo.AddIndicator(context.Background(), "123456789", repository.Entry{Difficulty: 1})
+
+	// Assert
+	assert.Equal(t, repository.ErrIndicatorExists, err)
+	entry, err := repo.GetIndicator(context.Background(), "123456789")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, entry.Difficulty)
+}
+
+func TestAddIndicatorConcurrently(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDB()
+	var wg sync.WaitGroup
+	var added int32
+
+	// Act
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if repo.AddIndicator(context.Background(), "123456789", repository.Entry{Difficulty: 5}) == nil {
+				atomic.AddInt32(&added, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	// the same nonce is never handed out twice
+	assert.Equal(t, int32(1), added)
+}
+
+func TestGetExpiredIndicator(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDB()
+	err := repo.AddIndicator(context.Background(), "short", repository.Entry{TTL: 10 * time.Millisecond})
+	assert.NoError(t, err)
+	err = repo.AddIndicator(context.Background(), "default", repository.Entry{})
+	assert.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	// Act
+	_, shortErr := repo.GetIndicator(context.Background(), "short")
+	_, defaultErr := repo.GetIndicator(context.Background(), "default")
+	// an expired indicator doesn't block issuing it again
+	addErr := repo.AddIndicator(context.Background(), "short", repository.Entry{})
+
+	// Assert
+	assert.Equal(t, repository.ErrIndicatorExpired, shortErr)
+	assert.NoError(t, defaultErr)
+	assert.NoError(t, addErr)
+}
+
+func TestAddIndicatorOverCapacity(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name            string
+		eviction        repository.EvictionPolicy
+		wantedErr       error
+		expectedOldest  error
+		expectedNewest  error
+		expectedEvicted uint64
+		expectedReject  uint64
+	}{
+		{
+			name:            "evict oldest",
+			eviction:        repository.EvictOldest,
+			expectedOldest:  repository.ErrIndicatorNotFound,
+			expectedEvicted: 1,
+		},
+		{
+			name:           "reject new",
+			eviction:       repository.RejectNew,
+			wantedErr:      repository.ErrCapacityExceeded,
+			expectedNewest: repository.ErrIndicatorNotFound,
+			expectedReject: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewInMemoryDBWithConfig(repository.Config{Capacity: 2, Eviction: tt.eviction})
+			assert.NoError(t, repo.AddIndicator(context.Background(), "oldest", repository.Entry{}))
+			assert.NoError(t, repo.AddIndicator(context.Background(), "older", repository.Entry{}))
+
+			// Act
+			err := repo.AddIndicator(context.Background(), "newest", repository.Entry{})
+
+			// Assert
+			assert.Equal(t, tt.wantedErr, err)
+			_, oldestErr := repo.GetIndicator(context.Background(), "oldest")
+			assert.Equal(t, tt.expectedOldest, oldestErr)
+			_, newestErr := repo.GetIndicator(context.Background(), "newest")
+			assert.Equal(t, tt.expectedNewest, newestErr)
+			stats := repo.(repository.StatsReporter).Stats()
+			assert.Equal(t, 2, stats.Size)
+			assert.Equal(t, tt.expectedEvicted, stats.Evicted)
+			assert.Equal(t, tt.expectedReject, stats.Rejected)
+		})
+	}
+}
+
+func TestRunJanitor(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDBWithConfig(repository.Config{TTL: 10 * time.Millisecond, SweepInterval: 5 * time.Millisecond})
+	assert.NoError(t, repo.AddIndicator(context.Background(), "expiring", repository.Entry{}))
+	assert.NoError(t, repo.AddIndicator(context.Background(), "kept", repository.Entry{TTL: time.Hour}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	// Act
+	go func() {
+		repo.(repository.Janitor).RunJanitor(ctx)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// Assert
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor didn't stop after the context was cancelled")
+	}
+	stats := repo.(repository.StatsReporter).Stats()
+	assert.Equal(t, 1, stats.Size)
+	assert.Equal(t, uint64(1), stats.Expired)
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisConfig - configures the redis repository
+type RedisConfig struct {
+	// Addr is the host:port of the redis server
+	Addr     string
+	Password string
+	DB       int
+	// KeyPrefix namespaces the indicator keys
+	KeyPrefix string
+	// TTL is how long indicators are kept unless their Entry sets its own TTL, redis expires them by itself
+	TTL time.Duration
+	// PoolSize is the maximum count of open connections
+	PoolSize int
+	// DialTimeout and IOTimeout bound every dial and command that has no earlier context deadline
+	DialTimeout time.Duration
+	IOTimeout   time.Duration
+}
+
+// DefaultRedisConfig - a local redis, indicators live for 10 minutes like in memory
+func DefaultRedisConfig() RedisConfig {
+	return RedisConfig{
+		Addr:        "localhost:6379",
+		KeyPrefix:   "wow:indicator:",
+		TTL:         DefaultConfig().TTL,
+		PoolSize:    10,
+		DialTimeout: 5 * time.Second,
+		IOTimeout:   3 * time.Second,
+	}
+}
+
+// errRedisClosed - returned by calls made after Close
+var errRedisClosed = errors.New("redis repository closed")
+
+type redisDB struct {
+	cfg RedisConfig
+	// slots limits the open connections, idle ones wait in idle for reuse
+	slots chan struct{}
+	idle  chan *redisConn
+	// mu guards closed, so no connection is put back into idle once Close drained it
+	mu     sync.Mutex
+	closed bool
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// NewRedisDB - creates a repository on a redis compatible server so replicas can share issued challenges,
+// zero config values fall back to DefaultRedisConfig. Connections are opened on first use.
+func NewRedisDB(cfg RedisConfig) Repository {
+	defaults := DefaultRedisConfig()
+	if cfg.Addr == "" {
+		cfg.Addr = defaults.Addr
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = defaults.KeyPrefix
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaults.TTL
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = defaults.PoolSize
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = defaults.DialTimeout
+	}
+	if cfg.IOTimeout <= 0 {
+		cfg.IOTimeout = defaults.IOTimeout
+	}
+	return &redisDB{
+		cfg:   cfg,
+		slots: make(chan struct{}, cfg.PoolSize),
+		idle:  make(chan *redisConn, cfg.PoolSize),
+	}
+}
+
+// AddIndicator - stores the indicator with SET NX EX, so of concurrent calls on any replica only one succeeds
+func (r *redisDB) AddIndicator(ctx context.Context, indicator string, entry Entry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	ttl := entry.TTL
+	if ttl <= 0 {
+		ttl = r.cfg.TTL
+	}
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// EX takes whole seconds, round up so an indicator never expires early
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+
+	reply, err := r.do(ctx, "SET", r.key(indicator), string(value), "NX", "EX", strconv.FormatInt(seconds, 10))
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return ErrIndicatorExists
+	}
+	return nil
+}
+
+// GetIndicator - returns indicator's entry, expired indicators are already gone from redis
+func (r *redisDB) GetIndicator(ctx context.Context, indicator string) (Entry, error) {
+	reply, err := r.do(ctx, "GET", r.key(indicator))
+	if err != nil {
+		return Entry{}, err
+	}
+	return decodeEntry(reply)
+}
+
+// ConsumeIndicator - returns indicator's entry and removes it in one atomic GETDEL
+func (r *redisDB) ConsumeIndicator(ctx context.Context, indicator string) (Entry, error) {
+	reply, err := r.do(ctx, "GETDEL", r.key(indicator))
+	if err != nil {
+		return Entry{}, err
+	}
+	return decodeEntry(reply)
+}
+
+// RemoveIndicator - removes indicator from redis
+func (r *redisDB) RemoveIndicator(ctx context.Context, indicator string) {
+	_, _ = r.do(ctx, "DEL", r.key(indicator))
+}
+
+// Close - closes the idle connections, connections in use are closed when they are released.
+// Calls made afterwards fail.
+func (r *redisDB) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	for {
+		select {
+		case c := <-r.idle:
+			c.conn.Close()
+			<-r.slots
+		default:
+			return nil
+		}
+	}
+}
+
+func (r *redisDB) key(indicator string) string {
+	return r.cfg.KeyPrefix + indicator
+}
+
+func decodeEntry(reply interface{}) (Entry, error) {
+	if reply == nil {
+		return Entry{}, ErrIndicatorNotFound
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return Entry{}, fmt.Errorf("%w: expected bulk string, got %T", errInvalidReply, reply)
+	}
+	var entry Entry
+	if err := json.Unmarshal([]byte(value), &entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// do - runs a single command on a pooled connection, broken connections are dropped instead of reused
+func (r *redisDB) do(ctx context.Context, args ...string) (interface{}, error) {
+	c, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.roundTrip(ctx, r.cfg.IOTimeout, args...)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// the connection is out of sync after a network error or timeout
+		c.conn.Close()
+		<-r.slots
+		return nil, err
+	}
+	r.release(c)
+	return reply, err
+}
+
+// release - puts the connection back into the pool, or closes it when the repository was closed meanwhile
+func (r *redisDB) release(c *redisConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		c.conn.Close()
+		<-r.slots
+		return
+	}
+	// never blocks, there are no more connections than idle has room for
+	r.idle <- c
+}
+
+// acquire - returns an idle connection, dials a new one when the pool has room or waits for one to be released
+func (r *redisDB) acquire(ctx context.Context) (*redisConn, error) {
+	r.mu.Lock()
+	closed := r.closed
+	r.mu.Unlock()
+	if closed {
+		return nil, errRedisClosed
+	}
+
+	select {
+	case c := <-r.idle:
+		return c, nil
+	default:
+	}
+
+	select {
+	case c := <-r.idle:
+		return c, nil
+	case r.slots <- struct{}{}:
+		c, err := r.dial(ctx)
+		if err != nil {
+			<-r.slots
+			return nil, err
+		}
+		return c, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *redisDB) dial(ctx context.Context) (*redisConn, error) {
+	dialer := net.Dialer{Timeout: r.cfg.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", r.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+
+	if r.cfg.Password != "" {
+		if _, err := c.roundTrip(ctx, r.cfg.IOTimeout, "AUTH", r.cfg.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.cfg.DB != 0 {
+		if _, err := c.roundTrip(ctx, r.cfg.IOTimeout, "SELECT", strconv.Itoa(r.cfg.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// roundTrip - sends the command and reads its reply before the context's deadline or the timeout, whichever is first.
+// A cancelled context interrupts the command as well.
+func (c *redisConn) roundTrip(ctx context.Context, timeout time.Duration, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if done := ctx.Done(); done != nil {
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-done:
+				_ = c.conn.SetDeadline(time.Unix(1, 0))
+			case <-stop:
+			}
+		}()
+		// the connection must not be released while the watcher can still touch its deadline
+		defer func() {
+			close(stop)
+			<-stopped
+		}()
+	}
+
+	if err := writeCommand(c.writer, args...); err != nil {
+		return nil, c.contextErr(ctx, err)
+	}
+	reply, err := readReply(c.reader)
+	if err != nil {
+		return nil, c.contextErr(ctx, err)
+	}
+	return reply, nil
+}
+
+// contextErr - reports the context's error instead of the i/o timeout it caused
+func (c *redisConn) contextErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	// the connection deadline can fire a moment before the context notices its own
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
@@ -0,0 +1,350 @@
+package repository_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis - an in-process stand-in speaking just enough RESP for the redis repository
+type fakeRedis struct {
+	listener net.Listener
+	password string
+	// hang makes the server read commands without ever replying
+	hang bool
+
+	mu        sync.Mutex
+	values    map[string]string
+	expiresAt map[string]time.Time
+	conns     int32
+	maxConns  int32
+}
+
+func newFakeRedis(t *testing.T, password string, hang bool) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	f := &fakeRedis{listener: l, password: password, hang: hang, values: map[string]string{}, expiresAt: map[string]time.Time{}}
+	go f.serve()
+	t.Cleanup(func() { l.Close() })
+	return f
+}
+
+func (f *fakeRedis) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	open := atomic.AddInt32(&f.conns, 1)
+	defer atomic.AddInt32(&f.conns, -1)
+	for {
+		max := atomic.LoadInt32(&f.maxConns)
+		if open <= max || atomic.CompareAndSwapInt32(&f.maxConns, max, open) {
+			break
+		}
+	}
+
+	reader := bufio.NewReader(conn)
+	authenticated := f.password == ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if f.hang {
+			continue
+		}
+		if strings.ToUpper(args[0]) == "AUTH" {
+			authenticated = len(args) == 2 && args[1] == f.password
+			if !authenticated {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			io.WriteString(conn, "+OK\r\n")
+			continue
+		}
+		if !authenticated {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		io.WriteString(conn, f.exec(args))
+	}
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+		if exp, ok := f.expiresAt[key]; ok && !time.Now().Before(exp) {
+			delete(f.values, key)
+			delete(f.expiresAt, key)
+		}
+	}
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		_, exists := f.values[key]
+		var ex time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				if exists {
+					return "$-1\r\n"
+				}
+			case "EX":
+				seconds, _ := strconv.Atoi(args[i+1])
+				ex = time.Duration(seconds) * time.Second
+				i++
+			}
+		}
+		f.values[key] = args[2]
+		if ex > 0 {
+			f.expiresAt[key] = time.Now().Add(ex)
+		}
+		return "+OK\r\n"
+	case "GET", "GETDEL":
+		value, ok := f.values[key]
+		if !ok {
+			return "$-1\r\n"
+		}
+		if strings.ToUpper(args[0]) == "GETDEL" {
+			delete(f.values, key)
+			delete(f.expiresAt, key)
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "DEL":
+		_, ok := f.values[key]
+		delete(f.values, key)
+		delete(f.expiresAt, key)
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func TestRedisDB(t *testing.T) {
+	// Arrange
+	fake := newFakeRedis(t, "", false)
+	repo := repository.NewRedisDB(repository.RedisConfig{Addr: fake.addr()})
+	ctx := context.Background()
+
+	// Act
+	addErr := repo.AddIndicator(ctx, "123456789", repository.Entry{Difficulty: 5, Version: 2})
+	existsErr := repo.AddIndicator(ctx, "123456789", repository.Entry{Difficulty: 1})
+	entry, getErr := repo.GetIndicator(ctx, "123456789")
+	repo.RemoveIndicator(ctx, "123456789")
+	_, removedErr := repo.GetIndicator(ctx, "123456789")
+
+	// Assert
+	assert.NoError(t, addErr)
+	assert.Equal(t, repository.ErrIndicatorExists, existsErr)
+	assert.NoError(t, getErr)
+	assert.Equal(t, 5, entry.Difficulty)
+	assert.Equal(t, 2, entry.Version)
+	assert.False(t, entry.CreatedAt.IsZero())
+	assert.Equal(t, repository.ErrIndicatorNotFound, removedErr)
+}
+
+func TestRedisDBKeyPrefix(t *testing.T) {
+	tests := []struct {
+		name      string
+		keyPrefix string
+		wantedKey string
+	}{
+		{name: "default prefix", wantedKey: "wow:indicator:123456789"},
+		{name: "custom prefix", keyPrefix: "staging:", wantedKey: "staging:123456789"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			fake := newFakeRedis(t, "", false)
+			repo := repository.NewRedisDB(repository.RedisConfig{Addr: fake.addr(), KeyPrefix: tt.keyPrefix})
+
+			// Act
+			err := repo.AddIndicator(context.Background(), "123456789", repository.Entry{Difficulty: 5})
+
+			// Assert
+			assert.NoError(t, err)
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			var keys []string
+			for key := range fake.values {
+				keys = append(keys, key)
+			}
+			assert.Equal(t, []string{tt.wantedKey}, keys)
+		})
+	}
+}
+
+func TestRedisDBSharedBetweenReplicas(t *testing.T) {
+	// Arrange
+	fake := newFakeRedis(t, "", false)
+	issuer := repository.NewRedisDB(repository.RedisConfig{Addr: fake.addr()})
+	redeemer := repository.NewRedisDB(repository.RedisConfig{Addr: fake.addr()})
+	assert.NoError(t, issuer.AddIndicator(context.Background(), "123456789", repository.Entry{Difficulty: 5}))
+
+	// Act
+	entry, err := redeemer.ConsumeIndicator(context.Background(), "123456789")
+	_, consumedErr := issuer.GetIndicator(context.Background(), "123456789")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 5, entry.Difficulty)
+	assert.Equal(t, repository.ErrIndicatorNotFound, consumedErr)
+}
+
+func TestRedisDBExpiry(t *testing.T) {
+	// Arrange
+	fake := newFakeRedis(t, "", false)
+	repo := repository.NewRedisDB(repository.RedisConfig{Addr: fake.addr()})
+	assert.NoError(t, repo.AddIndicator(context.Background(), "123456789", repository.Entry{TTL: 10 * time.Millisecond}))
+
+	// Act
+	_, freshErr := repo.GetIndicator(context.Background(), "123456789")
+	// EX only takes whole seconds, the TTL is rounded up
+	time.Sleep(1100 * time.Millisecond)
+	_, expiredErr := repo.GetIndicator(context.Background(), "123456789")
+
+	// Assert
+	assert.NoError(t, freshErr)
+	assert.Equal(t, repository.ErrIndicatorNotFound, expiredErr)
+}
+
+func TestRedisDBAuth(t *testing.T) {
+	// Arrange
+	fake := newFakeRedis(t, "secret", false)
+	repo := repository.NewRedisDB(repository.RedisConfig{Addr: fake.addr(), Password: "secret"})
+	wrongPassword := repository.NewRedisDB(repository.RedisConfig{Addr: fake.addr(), Password: "guess"})
+
+	// Act
+	err := repo.AddIndicator(context.Background(), "123456789", repository.Entry{})
+	wrongErr := wrongPassword.AddIndicator(context.Background(), "123456789", repository.Entry{})
+
+	// Assert
+	assert.NoError(t, err)
+	var redisErr repository.RedisError
+	assert.ErrorAs(t, wrongErr, &redisErr)
+}
+
+func TestRedisDBContextTimeout(t *testing.T) {
+	// Arrange
+	fake := newFakeRedis(t, "", true)
+	repo := repository.NewRedisDB(repository.RedisConfig{Addr: fake.addr()})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Act
+	start := time.Now()
+	_, err := repo.GetIndicator(ctx, "123456789")
+
+	// Assert
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestRedisDBPool(t *testing.T) {
+	// Arrange
+	fake := newFakeRedis(t, "", false)
+	repo := repository.NewRedisDB(repository.RedisConfig{Addr: fake.addr(), PoolSize: 3})
+	var wg sync.WaitGroup
+	var failed int32
+
+	// Act
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if repo.AddIndicator(context.Background(), strconv.Itoa(i), repository.Entry{}) != nil {
+				atomic.AddInt32(&failed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Assert
+	assert.Equal(t, int32(0), failed)
+	assert.LessOrEqual(t, atomic.LoadInt32(&fake.maxConns), int32(3))
+}
+
+func TestRedisDBCloseWhileInUse(t *testing.T) {
+	// Arrange
+	fake := newFakeRedis(t, "", false)
+	repo := repository.NewRedisDB(repository.RedisConfig{Addr: fake.addr()})
+	// replies wait for the lock, so the connection stays in use until it's released
+	fake.mu.Lock()
+	added := make(chan error, 1)
+	go func() {
+		added <- repo.AddIndicator(context.Background(), "123456789", repository.Entry{})
+	}()
+	for atomic.LoadInt32(&fake.conns) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Act
+	closeErr := repo.(io.Closer).Close()
+	fake.mu.Unlock()
+	addErr := <-added
+	_, afterCloseErr := repo.GetIndicator(context.Background(), "123456789")
+
+	// Assert
+	assert.NoError(t, closeErr)
+	assert.NoError(t, addErr)
+	assert.Error(t, afterCloseErr)
+	// the connection was closed instead of pooled
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&fake.conns) == 0 }, time.Second, time.Millisecond)
+}
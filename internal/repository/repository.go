@@ -0,0 +1,65 @@
+// Used to track the random nonces used in hashcash.Stamps to prevent replay attacks
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrIndicatorNotFound = errors.New("indicator not existing in inmemry db")
+	ErrIndicatorExists   = errors.New("indicator already existing in inmemory db")
+	ErrIndicatorExpired  = errors.New("indicator expired")
+	ErrCapacityExceeded  = errors.New("indicator capacity exceeded")
+	// ErrRepositoryUnusable is returned by every call after the repository lost its storage, e.g. a failed compaction
+	ErrRepositoryUnusable = errors.New("indicator repository unusable")
+)
+
+// Entry - details recorded for an issued indicator
+type Entry struct {
+	// Difficulty and Version are the ZerosCount and stamp version the challenge was minted with
+	Difficulty int
+	Version    int
+	// Binding describes the client the challenge was issued to, solutions from any other are rejected
+	Binding string
+	// Subnet is the subnet of the client the challenge was issued to, whose pending challenge quota it counts against
+	Subnet    string
+	CreatedAt time.Time
+	// TTL is how long the indicator is kept after CreatedAt, zero uses the repository's default
+	TTL time.Duration
+}
+
+type Repository interface {
+	// AddIndicator - stores the indicator, ErrIndicatorExists is returned when it's already stored
+	AddIndicator(ctx context.Context, indicator string, entry Entry) error
+	// GetIndicator - returns the stored entry, ErrIndicatorExpired once its TTL has passed
+	GetIndicator(ctx context.Context, indicator string) (Entry, error)
+	// ConsumeIndicator - returns the stored entry and removes it atomically, so of concurrent calls
+	// with the same indicator only one gets the entry and the others ErrIndicatorNotFound
+	ConsumeIndicator(ctx context.Context, indicator string) (Entry, error)
+	RemoveIndicator(ctx context.Context, indicator string)
+}
+
+// Janitor - implemented by repositories that have to remove expired indicators themselves
+type Janitor interface {
+	// RunJanitor - sweeps expired indicators periodically until the context is cancelled
+	RunJanitor(ctx context.Context)
+}
+
+// Stats - counters of indicators that left the repository without being removed
+type Stats struct {
+	// Size is the count of currently stored indicators
+	Size int
+	// Expired indicators were swept after their TTL
+	Expired uint64
+	// Evicted indicators were dropped to make room for new ones
+	Evicted uint64
+	// Rejected indicators were refused because the repository was full
+	Rejected uint64
+}
+
+// StatsReporter - implemented by repositories that keep Stats
+type StatsReporter interface {
+	Stats() Stats
+}
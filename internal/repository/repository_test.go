@@ -0,0 +1,73 @@
+package repository_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumeIndicatorConcurrently(t *testing.T) {
+	// Arrange
+	tests := []struct {
+		name    string
+		newRepo func(t *testing.T) repository.Repository
+	}{
+		{
+			name: "in memory",
+			newRepo: func(t *testing.T) repository.Repository {
+				return repository.NewInMemoryDB()
+			},
+		},
+		{
+			name: "redis",
+			newRepo: func(t *testing.T) repository.Repository {
+				return repository.NewRedisDB(repository.RedisConfig{Addr: newFakeRedis(t, "", false).addr()})
+			},
+		},
+		{
+			name: "bolt",
+			newRepo: func(t *testing.T) repository.Repository {
+				repo := newBoltDB(t, repository.BoltConfig{Path: filepath.Join(t.TempDir(), "indicators.db")})
+				t.Cleanup(func() { repo.Close() })
+				return repo
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := tt.newRepo(t)
+			assert.NoError(t, repo.AddIndicator(context.Background(), "123456789", repository.Entry{Difficulty: 5}))
+			var wg sync.WaitGroup
+			var consumed, notFound int32
+
+			// Act
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					entry, err := repo.ConsumeIndicator(context.Background(), "123456789")
+					switch err {
+					case nil:
+						assert.Equal(t, 5, entry.Difficulty)
+						atomic.AddInt32(&consumed, 1)
+					case repository.ErrIndicatorNotFound:
+						atomic.AddInt32(&notFound, 1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			// Assert
+			assert.Equal(t, int32(1), consumed)
+			assert.Equal(t, int32(49), notFound)
+			_, err := repo.GetIndicator(context.Background(), "123456789")
+			assert.Equal(t, repository.ErrIndicatorNotFound, err)
+		})
+	}
+}
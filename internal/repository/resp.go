@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxBulkSize - replies larger than this are refused instead of allocated, entries are tiny json documents
+const maxBulkSize = 1 << 20
+
+var errInvalidReply = errors.New("invalid redis reply")
+
+// RedisError - an error reply sent by the server, e.g. a wrong password
+type RedisError string
+
+// Error - implements error
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// writeCommand - encodes the command as a RESP array of bulk strings
+func writeCommand(w *bufio.Writer, args ...string) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return w.Flush()
+}
+
+// readReply - decodes a single RESP reply. Simple strings and bulk strings are returned as string,
+// integers as int64, a null bulk string as nil and error replies as RedisError.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errInvalidReply
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, errInvalidReply
+		}
+		return n, nil
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size > maxBulkSize {
+			return nil, errInvalidReply
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		bulk := make([]byte, size+2)
+		if _, err := io.ReadFull(r, bulk); err != nil {
+			return nil, err
+		}
+		return string(bulk[:size]), nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected type %q", errInvalidReply, line[0])
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errInvalidReply
+	}
+	return line[:len(line)-2], nil
+}
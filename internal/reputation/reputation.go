@@ -0,0 +1,270 @@
+// Keeps a decaying misbehaviour score per client subnet, used to make abusive clients solve harder challenges
+package reputation
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event - kind of misbehaviour that lowers a client's reputation
+type Event int
+
+const (
+	FailedSolution Event = iota
+	Replay
+	MalformedMessage
+	Burst
+)
+
+// String - returns a readable event name
+func (e Event) String() string {
+	switch e {
+	case FailedSolution:
+		return "failed_solution"
+	case Replay:
+		return "replay"
+	case MalformedMessage:
+		return "malformed_message"
+	case Burst:
+		return "burst"
+	default:
+		return fmt.Sprintf("event_%d", int(e))
+	}
+}
+
+// Config - tuning of the reputation tracker
+type Config struct {
+	// HalfLife is the time it takes for a score to decay to half of its value
+	HalfLife time.Duration
+	// IPv4Prefix and IPv6Prefix are the subnet sizes clients are grouped by
+	IPv4Prefix int
+	IPv6Prefix int
+	// Weights is how much every event adds to the score
+	Weights map[Event]float64
+	// A client sending more than BurstLimit requests within BurstWindow is recorded as a Burst
+	BurstWindow time.Duration
+	BurstLimit  int
+	// PointsPerZero is the score needed for every additional leading zero hex character, capped at MaxPenalty
+	PointsPerZero float64
+	MaxPenalty    int
+	// MaxEntries triggers pruning of fully decayed entries
+	MaxEntries int
+}
+
+// DefaultConfig - returns the configuration used when none is provided
+func DefaultConfig() Config {
+	return Config{
+		HalfLife:   10 * time.Minute,
+		IPv4Prefix: 24,
+		IPv6Prefix: 64,
+		Weights: map[Event]float64{
+			FailedSolution:   2,
+			Replay:           4,
+			MalformedMessage: 3,
+			Burst:            1,
+		},
+		BurstWindow:   time.Second,
+		BurstLimit:    20,
+		PointsPerZero: 10,
+		MaxPenalty:    3,
+		MaxEntries:    100000,
+	}
+}
+
+// Report - debugging view of a single tracked subnet
+type Report struct {
+	Key      string
+	Score    float64
+	Penalty  int
+	Events   map[string]int
+	LastSeen time.Time
+}
+
+// String - formats the report for logs
+func (r Report) String() string {
+	return fmt.Sprintf("%s score=%.2f penalty=%d events=%v last_seen=%s",
+		r.Key, r.Score, r.Penalty, r.Events, r.LastSeen.Format(time.RFC3339))
+}
+
+type entry struct {
+	score       float64
+	decayedAt   time.Time
+	lastSeen    time.Time
+	events      map[Event]int
+	windowStart time.Time
+	windowCount int
+}
+
+// Tracker - keeps reputation scores keyed by client subnet
+type Tracker struct {
+	cfg     Config
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// NewTracker - creates a reputation tracker
+func NewTracker(cfg Config) *Tracker {
+	defaults := DefaultConfig()
+	if cfg.HalfLife <= 0 {
+		cfg.HalfLife = defaults.HalfLife
+	}
+	if cfg.IPv4Prefix <= 0 || cfg.IPv4Prefix > 32 {
+		cfg.IPv4Prefix = defaults.IPv4Prefix
+	}
+	if cfg.IPv6Prefix <= 0 || cfg.IPv6Prefix > 128 {
+		cfg.IPv6Prefix = defaults.IPv6Prefix
+	}
+	if cfg.Weights == nil {
+		cfg.Weights = defaults.Weights
+	}
+	if cfg.PointsPerZero <= 0 {
+		cfg.PointsPerZero = defaults.PointsPerZero
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaults.MaxEntries
+	}
+	return &Tracker{cfg: cfg, entries: map[string]*entry{}}
+}
+
+// Key - returns the subnet a client address is grouped by
+func (t *Tracker) Key(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(t.cfg.IPv4Prefix, 32)), Mask: net.CIDRMask(t.cfg.IPv4Prefix, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(t.cfg.IPv6Prefix, 128)), Mask: net.CIDRMask(t.cfg.IPv6Prefix, 128)}).String()
+}
+
+// Record - lowers the reputation of the client's subnet
+func (t *Tracker) Record(addr string, ev Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.record(t.entry(t.Key(addr), time.Now()), ev)
+}
+
+// ObserveRequest - counts a request from the client and records a Burst when it sends too many
+func (t *Tracker) ObserveRequest(addr string) {
+	if t.cfg.BurstLimit <= 0 || t.cfg.BurstWindow <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	e := t.entry(t.Key(addr), now)
+	if now.Sub(e.windowStart) > t.cfg.BurstWindow {
+		e.windowStart = now
+		e.windowCount = 0
+	}
+	e.windowCount++
+	if e.windowCount > t.cfg.BurstLimit {
+		t.record(e, Burst)
+	}
+}
+
+// Score - returns the current decayed score of the client's subnet
+func (t *Tracker) Score(addr string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[t.Key(addr)]
+	if !ok {
+		return 0
+	}
+	t.decay(e, time.Now())
+	return e.score
+}
+
+// Penalty - returns how many leading zero hex characters to add to the client's next challenge,
+// algorithms counting bits have to scale it to their unit
+func (t *Tracker) Penalty(addr string) int {
+	return t.penalty(t.Score(addr))
+}
+
+// Snapshot - returns all tracked subnets, worst first
+func (t *Tracker) Snapshot() []Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	reports := make([]Report, 0, len(t.entries))
+	for key, e := range t.entries {
+		t.decay(e, now)
+		events := make(map[string]int, len(e.events))
+		for ev, count := range e.events {
+			events[ev.String()] = count
+		}
+		reports = append(reports, Report{
+			Key:      key,
+			Score:    e.score,
+			Penalty:  t.penalty(e.score),
+			Events:   events,
+			LastSeen: e.lastSeen,
+		})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Score == reports[j].Score {
+			return reports[i].Key < reports[j].Key
+		}
+		return reports[i].Score > reports[j].Score
+	})
+	return reports
+}
+
+func (t *Tracker) penalty(score float64) int {
+	p := int(score / t.cfg.PointsPerZero)
+	if t.cfg.MaxPenalty > 0 && p > t.cfg.MaxPenalty {
+		p = t.cfg.MaxPenalty
+	}
+	return p
+}
+
+// entry - returns the decayed entry for key, creating it if needed, must be called with t.mu held
+func (t *Tracker) entry(key string, now time.Time) *entry {
+	e, ok := t.entries[key]
+	if !ok {
+		if len(t.entries) >= t.cfg.MaxEntries {
+			t.prune(now)
+		}
+		e = &entry{decayedAt: now, windowStart: now, events: map[Event]int{}}
+		t.entries[key] = e
+	}
+	t.decay(e, now)
+	e.lastSeen = now
+	return e
+}
+
+func (t *Tracker) record(e *entry, ev Event) {
+	e.score += t.cfg.Weights[ev]
+	e.events[ev]++
+}
+
+func (t *Tracker) decay(e *entry, now time.Time) {
+	elapsed := now.Sub(e.decayedAt)
+	if elapsed <= 0 {
+		return
+	}
+	e.score *= math.Pow(0.5, float64(elapsed)/float64(t.cfg.HalfLife))
+	e.decayedAt = now
+}
+
+// prune - drops entries that have decayed to nothing and are outside of their burst window
+func (t *Tracker) prune(now time.Time) {
+	for key, e := range t.entries {
+		t.decay(e, now)
+		if e.score < 0.01 && now.Sub(e.windowStart) > t.cfg.BurstWindow {
+			delete(t.entries, key)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package reputation_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/reputation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	// Arrange
+	tracker := reputation.NewTracker(reputation.DefaultConfig())
+	tests := []struct {
+		name        string
+		addr        string
+		expectedKey string
+	}{
+		{name: "ipv4 with port", addr: "192.168.1.77:5555", expectedKey: "192.168.1.0/24"},
+		{name: "ipv4 without port", addr: "10.0.0.1", expectedKey: "10.0.0.0/24"},
+		{name: "ipv6 with port", addr: "[2001:db8:1:2:3::4]:80", expectedKey: "2001:db8:1:2::/64"},
+		{name: "not an ip", addr: "testClient", expectedKey: "testClient"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			key := tracker.Key(tt.addr)
+
+			// Assert
+			assert.Equal(t, tt.expectedKey, key)
+		})
+	}
+}
+
+func TestPenaltyGrowsWithMisbehaviourOfSubnet(t *testing.T) {
+	// Arrange
+	cfg := reputation.DefaultConfig()
+	cfg.PointsPerZero = 3
+	cfg.MaxPenalty = 2
+	tracker := reputation.NewTracker(cfg)
+
+	// Act
+	tracker.Record("10.0.0.1:1000", reputation.Replay)
+	afterOne := tracker.Penalty("10.0.0.2:2000")
+	tracker.Record("10.0.0.3:1000", reputation.Replay)
+	tracker.Record("10.0.0.4:1000", reputation.Replay)
+	afterThree := tracker.Penalty("10.0.0.2:2000")
+
+	// Assert
+	assert.Equal(t, 1, afterOne)
+	assert.Equal(t, 2, afterThree)
+	assert.Equal(t, 0, tracker.Penalty("10.0.1.1:1000"))
+}
+
+func TestScoreDecays(t *testing.T) {
+	// Arrange
+	cfg := reputation.DefaultConfig()
+	cfg.HalfLife = 50 * time.Millisecond
+	tracker := reputation.NewTracker(cfg)
+	tracker.Record("10.0.0.1:1000", reputation.FailedSolution)
+
+	// Act
+	time.Sleep(100 * time.Millisecond)
+	score := tracker.Score("10.0.0.1:1000")
+
+	// Assert
+	assert.InDelta(t, cfg.Weights[reputation.FailedSolution]/4, score, 0.2)
+}
+
+func TestObserveRequestRecordsBurst(t *testing.T) {
+	// Arrange
+	cfg := reputation.DefaultConfig()
+	cfg.BurstLimit = 2
+	cfg.BurstWindow = time.Minute
+	tracker := reputation.NewTracker(cfg)
+
+	// Act
+	for i := 0; i < 4; i++ {
+		tracker.ObserveRequest("10.0.0.1:1000")
+	}
+	reports := tracker.Snapshot()
+
+	// Assert
+	assert.Len(t, reports, 1)
+	assert.Equal(t, "10.0.0.0/24", reports[0].Key)
+	assert.Equal(t, 2, reports[0].Events["burst"])
+}
+
+func TestSnapshotIsSortedByScore(t *testing.T) {
+	// Arrange
+	tracker := reputation.NewTracker(reputation.DefaultConfig())
+	tracker.Record("10.0.0.1:1000", reputation.FailedSolution)
+	tracker.Record("10.0.1.1:1000", reputation.Replay)
+
+	// Act
+	reports := tracker.Snapshot()
+
+	// Assert
+	assert.Len(t, reports, 2)
+	assert.Equal(t, "10.0.1.0/24", reports[0].Key)
+	assert.Equal(t, 1, reports[0].Events["replay"])
+	assert.Equal(t, "10.0.0.0/24", reports[1].Key)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Binding - what an issued challenge is tied to, its solution is only accepted from the same binding.
+// Bindings combine, e.g. BindAddress | BindSession.
+type Binding int
+
+const (
+	// BindNone - any client can redeem any challenge, solving can be outsourced freely
+	BindNone Binding = 0
+	// BindAddress - the solution has to come from the IP address the challenge was issued to,
+	// the port may change so a client can reconnect
+	BindAddress Binding = 1
+	// BindSession - the solution has to come on the connection the challenge was issued on
+	BindSession Binding = 2
+)
+
+// DefaultBinding - challenges are bound to the client's address
+const DefaultBinding = BindAddress
+
+// ParseBinding - parses a comma separated list of none, address and session
+func ParseBinding(value string) (Binding, error) {
+	binding := BindNone
+	for _, part := range strings.Split(value, ",") {
+		switch strings.TrimSpace(part) {
+		case "none":
+		case "address":
+			binding |= BindAddress
+		case "session":
+			binding |= BindSession
+		default:
+			return BindNone, fmt.Errorf("unknown challenge binding %q", part)
+		}
+	}
+	return binding, nil
+}
+
+type sessionKey struct{}
+
+// ContextWithSession - ties the requests processed with the context to a session, handleConnection starts one per connection.
+// Callers of ProcessRequest use it to bind challenges to sessions of their own, requests without one share the empty session.
+func ContextWithSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+func sessionFrom(ctx context.Context) string {
+	session, _ := ctx.Value(sessionKey{}).(string)
+	return session
+}
+
+// bindingOf - describes the requesting client in terms of the server's binding,
+// challenges remember it when issued and solutions have to match it
+func (s *tcpServer) bindingOf(ctx context.Context, clientDetails string) string {
+	var parts []string
+	if s.binding&BindAddress != 0 {
+		parts = append(parts, "ip="+clientIP(clientDetails))
+	}
+	if s.binding&BindSession != 0 {
+		parts = append(parts, "session="+sessionFrom(ctx))
+	}
+	return strings.Join(parts, ";")
+}
@@ -2,8 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/Lockwarr/WordOfWisdom/internal/challenge"
+	"github.com/Lockwarr/WordOfWisdom/internal/pow"
+	"github.com/Lockwarr/WordOfWisdom/internal/quotes"
 	"github.com/Lockwarr/WordOfWisdom/internal/repository"
+	"github.com/Lockwarr/WordOfWisdom/internal/reputation"
 	"github.com/Lockwarr/WordOfWisdom/server"
 )
 
@@ -12,6 +25,189 @@ const port = "8080"
 const host = "0.0.0.0"
 
 func main() {
-	tcpSrvr := server.NewTCPServer(host, port, repository.NewInMemoryDB())
-	tcpSrvr.Start(context.Background())
+	// SIGTERM, e.g. sent on every deploy, stops the server after the clients finished their exchange
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	tracker := reputation.NewTracker(reputation.DefaultConfig())
+	repo, err := newRepository()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if janitor, ok := repo.(repository.Janitor); ok {
+		go janitor.RunJanitor(ctx)
+	}
+	go dumpStatsOnSignal(tracker, repo)
+	store, err := newQuoteStore(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	selector, err := newQuoteSelector()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// POW_ALGORITHM is one of hashcash-sha1 (default), hashcash-sha256 or argon2id
+	alg, err := pow.Lookup(os.Getenv("POW_ALGORITHM"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// POW_DIFFICULTY_UNIT=bits switches SHA-1 hashcash to bit granular stamps, the server scales the difficulty
+	// so they cost as much
+	if alg.Name() == pow.SHA1Hashcash && os.Getenv("POW_DIFFICULTY_UNIT") == "bits" {
+		alg = pow.NewSHA1HashcashBits()
+	}
+
+	opts := []server.Option{
+		server.WithReputation(tracker),
+		server.WithAlgorithm(alg),
+		server.WithSelector(selector),
+	}
+	limits, err := connectionLimits()
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts = append(opts, server.WithConnectionLimits(limits))
+	// CHALLENGE_BINDING ties challenges to the client's address (default), its connection or both, e.g. "address,session"
+	if value := os.Getenv("CHALLENGE_BINDING"); value != "" {
+		binding, err := server.ParseBinding(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, server.WithBinding(binding))
+	}
+	// CHALLENGE_SECRET switches to stateless, signed challenges, every instance behind a load balancer needs the same secret
+	if secret := os.Getenv("CHALLENGE_SECRET"); secret != "" {
+		cfg := challenge.DefaultConfig()
+		cfg.Secret = []byte(secret)
+		signer, err := challenge.NewSigner(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, server.WithStatelessChallenges(signer))
+	}
+
+	tcpSrvr := server.NewTCPServer(host, port, repo, store, opts...)
+	err = tcpSrvr.Start(ctx)
+	if closer, ok := repo.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Println("err close repository:", err)
+		}
+	}
+	if !errors.Is(err, server.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	log.Println(err)
+}
+
+// newRepository - picks the repository backend with REPOSITORY, memory (default), redis or bolt.
+// Replicas sharing a redis accept each other's challenges, REDIS_ADDR and REDIS_PASSWORD configure the connection.
+// bolt keeps challenges in the file at BOLT_PATH, so a single node keeps them across restarts.
+func newRepository() (repository.Repository, error) {
+	switch backend := os.Getenv("REPOSITORY"); backend {
+	case "", "memory":
+		return repository.NewInMemoryDB(), nil
+	case "redis":
+		cfg := repository.DefaultRedisConfig()
+		if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+			cfg.Addr = addr
+		}
+		cfg.Password = os.Getenv("REDIS_PASSWORD")
+		return repository.NewRedisDB(cfg), nil
+	case "bolt":
+		cfg := repository.DefaultBoltConfig()
+		if path := os.Getenv("BOLT_PATH"); path != "" {
+			cfg.Path = path
+		}
+		return repository.NewBoltDB(cfg)
+	default:
+		return nil, fmt.Errorf("unknown repository backend %q", backend)
+	}
+}
+
+// connectionLimits - MAX_CONNECTIONS and MAX_CONNECTIONS_PER_IP override the default caps, 0 lifts them.
+// CONNECTION_POLICY=wait makes connections over MAX_CONNECTIONS wait for a free slot instead of being rejected.
+func connectionLimits() (server.LimitsConfig, error) {
+	limits := server.DefaultLimitsConfig()
+	for env, limit := range map[string]*int{
+		"MAX_CONNECTIONS":        &limits.MaxConnections,
+		"MAX_CONNECTIONS_PER_IP": &limits.MaxConnectionsPerIP,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return limits, fmt.Errorf("invalid %s %q", env, value)
+		}
+		*limit = n
+	}
+	switch policy := os.Getenv("CONNECTION_POLICY"); policy {
+	case "", "reject":
+		limits.Policy = server.RejectWhenFull
+	case "wait":
+		limits.Policy = server.WaitWhenFull
+	default:
+		return limits, fmt.Errorf("unknown connection policy %q", policy)
+	}
+	return limits, nil
+}
+
+// newQuoteStore - serves the quotes at QUOTES_PATH, a json, yaml or csv file or a directory of text files.
+// The corpus is reloaded when it changes, checked every QUOTES_POLL_INTERVAL (5s by default, 0 disables polling),
+// and on SIGHUP. The corpus built into the binary is served when QUOTES_PATH isn't set.
+func newQuoteStore(ctx context.Context) (quotes.Store, error) {
+	path := os.Getenv("QUOTES_PATH")
+	if path == "" {
+		return quotes.NewEmbeddedStore(), nil
+	}
+	interval := quotes.DefaultPollInterval
+	if value := os.Getenv("QUOTES_POLL_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid QUOTES_POLL_INTERVAL %q", value)
+		}
+		interval = d
+	}
+	store, err := quotes.NewReloadingStore(path)
+	if err != nil {
+		return nil, err
+	}
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go store.Watch(ctx, interval, reload)
+	return store, nil
+}
+
+// newQuoteSelector - QUOTE_STRATEGY picks how quotes are served: uniform (default) random, weighted by the quotes'
+// weight, shuffle so a client sees every quote before one repeats, or daily, the same quote all day long.
+// QUOTE_TIMEZONE is the IANA time zone the day of the daily quote starts in, UTC by default.
+func newQuoteSelector() (quotes.Selector, error) {
+	cfg := quotes.DefaultSelectorConfig()
+	cfg.Strategy = quotes.Strategy(os.Getenv("QUOTE_STRATEGY"))
+	if name := os.Getenv("QUOTE_TIMEZONE"); name != "" {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QUOTE_TIMEZONE %q: %w", name, err)
+		}
+		cfg.Location = location
+	}
+	return quotes.NewSelector(cfg)
+}
+
+// dumpStatsOnSignal - logs the tracked client reputations and repository counters every time SIGUSR1 is received
+func dumpStatsOnSignal(tracker *reputation.Tracker, repo repository.Repository) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	for range signals {
+		reports := tracker.Snapshot()
+		log.Printf("reputation: %d tracked subnets", len(reports))
+		for _, report := range reports {
+			log.Println("reputation:", report)
+		}
+		if reporter, ok := repo.(repository.StatsReporter); ok {
+			stats := reporter.Stats()
+			log.Printf("repository: %d indicators, %d expired, %d evicted, %d rejected", stats.Size, stats.Expired, stats.Evicted, stats.Rejected)
+		}
+	}
 }
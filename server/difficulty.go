@@ -0,0 +1,183 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/pow"
+)
+
+// DifficultyConfig - bounds and load thresholds used to tune the proof of work difficulty
+type DifficultyConfig struct {
+	// MinZeros and MaxZeros bound the ZerosCount of issued stamps, counted in leading zero hex characters of SHA-1.
+	// The server scales them with the DifficultyPerHexZero and AttemptCost of its algorithm,
+	// so every algorithm takes about as long to solve.
+	MinZeros int
+	MaxZeros int
+	// Step is how much ZerosCount changes on every adjustment, in the unit of the algorithm,
+	// so algorithms counting bits adjust in finer steps
+	Step int
+	// Window is how often the load is evaluated
+	Window time.Duration
+	// The server is considered overloaded when any of these is exceeded
+	MaxConnections       int
+	MaxRequestsPerSecond float64
+	MaxLatency           time.Duration
+}
+
+// DefaultDifficultyConfig - returns the configuration used when none is provided.
+// MaxZeros stays solvable within DefaultSolveWindow on a single core, 7 hex zeros would take 2^28 SHA-1 attempts.
+func DefaultDifficultyConfig() DifficultyConfig {
+	return DifficultyConfig{
+		MinZeros:             5,
+		MaxZeros:             6,
+		Step:                 1,
+		Window:               10 * time.Second,
+		MaxConnections:       500,
+		MaxRequestsPerSecond: 200,
+		MaxLatency:           200 * time.Millisecond,
+	}
+}
+
+// scaled - the configuration with the bounds in the unit of the algorithm and lowered by the cost of its attempts
+func (cfg DifficultyConfig) scaled(alg pow.Algorithm) DifficultyConfig {
+	cfg.MinZeros = scaleZeros(cfg.MinZeros, alg)
+	cfg.MaxZeros = scaleZeros(cfg.MaxZeros, alg)
+	return cfg
+}
+
+// scaleZeros - converts hex zeros of SHA-1 to the difficulty of the algorithm taking as long to solve
+func scaleZeros(zeros int, alg pow.Algorithm) int {
+	if zeros <= 0 {
+		return zeros
+	}
+	scaled := zeros*alg.DifficultyPerHexZero() - alg.AttemptCost()
+	// some work is asked for whatever the cost of an attempt
+	if scaled < 1 {
+		return 1
+	}
+	return scaled
+}
+
+// DifficultyStats - snapshot of the load observed by the controller
+type DifficultyStats struct {
+	ZerosCount        int
+	ActiveConnections int
+	RequestsPerSecond float64
+	AverageLatency    time.Duration
+}
+
+// DifficultyController - raises or lowers the proof of work difficulty based on the server load
+type DifficultyController struct {
+	cfg DifficultyConfig
+
+	mu          sync.Mutex
+	current     int
+	activeConns int
+	requests    int
+	latencySum  time.Duration
+	windowStart time.Time
+	last        DifficultyStats
+}
+
+// NewDifficultyController - creates a controller starting at the lowest difficulty
+func NewDifficultyController(cfg DifficultyConfig) *DifficultyController {
+	if cfg.Step <= 0 {
+		cfg.Step = 1
+	}
+	if cfg.MaxZeros < cfg.MinZeros {
+		cfg.MaxZeros = cfg.MinZeros
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultDifficultyConfig().Window
+	}
+	return &DifficultyController{
+		cfg:         cfg,
+		current:     cfg.MinZeros,
+		windowStart: time.Now(),
+		last:        DifficultyStats{ZerosCount: cfg.MinZeros},
+	}
+}
+
+// ConnectionOpened - registers a new live connection
+func (d *DifficultyController) ConnectionOpened() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.activeConns++
+}
+
+// ConnectionClosed - unregisters a live connection
+func (d *DifficultyController) ConnectionClosed() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.activeConns > 0 {
+		d.activeConns--
+	}
+}
+
+// ObserveRequest - records a handled request and the time it took
+func (d *DifficultyController) ObserveRequest(latency time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests++
+	d.latencySum += latency
+}
+
+// Difficulty - returns the ZerosCount to use for a new challenge
+func (d *DifficultyController) Difficulty() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.adjust(time.Now())
+	return d.current
+}
+
+// Stats - returns the load observed during the last completed window
+func (d *DifficultyController) Stats() DifficultyStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.adjust(time.Now())
+	return d.last
+}
+
+// adjust - evaluates the load once per window, must be called with d.mu held
+func (d *DifficultyController) adjust(now time.Time) {
+	elapsed := now.Sub(d.windowStart)
+	if elapsed < d.cfg.Window {
+		return
+	}
+
+	stats := DifficultyStats{
+		ActiveConnections: d.activeConns,
+		RequestsPerSecond: float64(d.requests) / elapsed.Seconds(),
+	}
+	if d.requests > 0 {
+		stats.AverageLatency = d.latencySum / time.Duration(d.requests)
+	}
+
+	overloaded := (d.cfg.MaxConnections > 0 && stats.ActiveConnections > d.cfg.MaxConnections) ||
+		(d.cfg.MaxRequestsPerSecond > 0 && stats.RequestsPerSecond > d.cfg.MaxRequestsPerSecond) ||
+		(d.cfg.MaxLatency > 0 && stats.AverageLatency > d.cfg.MaxLatency)
+	// only lower the difficulty once the load is well below every threshold, so it doesn't flap
+	relaxed := (d.cfg.MaxConnections <= 0 || stats.ActiveConnections <= d.cfg.MaxConnections/2) &&
+		(d.cfg.MaxRequestsPerSecond <= 0 || stats.RequestsPerSecond <= d.cfg.MaxRequestsPerSecond/2) &&
+		(d.cfg.MaxLatency <= 0 || stats.AverageLatency <= d.cfg.MaxLatency/2)
+
+	switch {
+	case overloaded:
+		d.current += d.cfg.Step
+		if d.current > d.cfg.MaxZeros {
+			d.current = d.cfg.MaxZeros
+		}
+	case relaxed:
+		d.current -= d.cfg.Step
+		if d.current < d.cfg.MinZeros {
+			d.current = d.cfg.MinZeros
+		}
+	}
+
+	stats.ZerosCount = d.current
+	d.last = stats
+	d.requests = 0
+	d.latencySum = 0
+	d.windowStart = now
+}
@@ -0,0 +1,86 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/server"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDifficultyConfig() server.DifficultyConfig {
+	return server.DifficultyConfig{
+		MinZeros:             3,
+		MaxZeros:             5,
+		Step:                 1,
+		Window:               10 * time.Millisecond,
+		MaxConnections:       2,
+		MaxRequestsPerSecond: 1000000,
+		MaxLatency:           time.Second,
+	}
+}
+
+func TestDifficultyStartsAtMinimum(t *testing.T) {
+	// Arrange
+	controller := server.NewDifficultyController(testDifficultyConfig())
+
+	// Act
+	zeros := controller.Difficulty()
+
+	// Assert
+	assert.Equal(t, 3, zeros)
+}
+
+func TestDifficultyRaisesUnderLoadUpToMaximum(t *testing.T) {
+	// Arrange
+	controller := server.NewDifficultyController(testDifficultyConfig())
+	for i := 0; i < 3; i++ {
+		controller.ConnectionOpened()
+	}
+
+	// Act
+	var zeros []int
+	for i := 0; i < 4; i++ {
+		time.Sleep(15 * time.Millisecond)
+		zeros = append(zeros, controller.Difficulty())
+	}
+
+	// Assert
+	assert.Equal(t, []int{4, 5, 5, 5}, zeros)
+	assert.Equal(t, 3, controller.Stats().ActiveConnections)
+}
+
+func TestDifficultyRaisesOnSlowRequests(t *testing.T) {
+	// Arrange
+	controller := server.NewDifficultyController(testDifficultyConfig())
+	controller.ObserveRequest(2 * time.Second)
+
+	// Act
+	time.Sleep(15 * time.Millisecond)
+	zeros := controller.Difficulty()
+
+	// Assert
+	assert.Equal(t, 4, zeros)
+	assert.Equal(t, 2*time.Second, controller.Stats().AverageLatency)
+}
+
+func TestDifficultyLowersWhenLoadDrops(t *testing.T) {
+	// Arrange
+	controller := server.NewDifficultyController(testDifficultyConfig())
+	for i := 0; i < 3; i++ {
+		controller.ConnectionOpened()
+	}
+	time.Sleep(15 * time.Millisecond)
+	assert.Equal(t, 4, controller.Difficulty())
+
+	// Act
+	for i := 0; i < 3; i++ {
+		controller.ConnectionClosed()
+	}
+	time.Sleep(15 * time.Millisecond)
+	zeros := controller.Difficulty()
+
+	// Assert
+	assert.Equal(t, 3, zeros)
+}
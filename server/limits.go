@@ -0,0 +1,106 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
+)
+
+// FullPolicy - what happens to a connection accepted while MaxConnections are open
+type FullPolicy int
+
+const (
+	// RejectWhenFull - the connection is sent a too_many_connections error and closed
+	RejectWhenFull FullPolicy = iota
+	// WaitWhenFull - the connection waits for a free slot, further ones queue in the listener's backlog
+	WaitWhenFull
+)
+
+// LimitsConfig - caps on open connections, opening a socket costs nothing so these are enforced before any PoW
+type LimitsConfig struct {
+	// MaxConnections caps the open connections of all clients, zero is unlimited
+	MaxConnections int
+	// MaxConnectionsPerIP caps the open connections from a single IP address, zero is unlimited.
+	// Connections over it are always rejected, whatever the Policy.
+	MaxConnectionsPerIP int
+	// Policy applies once MaxConnections are open
+	Policy FullPolicy
+	// RetryAfter is how long rejected clients are asked to wait before reconnecting
+	RetryAfter time.Duration
+}
+
+// DefaultLimitsConfig - returns the configuration used when none is provided
+func DefaultLimitsConfig() LimitsConfig {
+	return LimitsConfig{
+		MaxConnections:      10000,
+		MaxConnectionsPerIP: 100,
+		Policy:              RejectWhenFull,
+		RetryAfter:          time.Second,
+	}
+}
+
+// connLimiter - hands out connection slots, globally and per IP address
+type connLimiter struct {
+	cfg LimitsConfig
+	// slots holds a token for every open connection, nil when unlimited
+	slots chan struct{}
+
+	mu    sync.Mutex
+	perIP map[string]int
+}
+
+func newConnLimiter(cfg LimitsConfig) *connLimiter {
+	l := &connLimiter{cfg: cfg, perIP: map[string]int{}}
+	if cfg.MaxConnections > 0 {
+		l.slots = make(chan struct{}, cfg.MaxConnections)
+	}
+	return l
+}
+
+// admit - takes a slot for a connection from the IP address, waiting for one with WaitWhenFull until stop is closed.
+// Returns the error to reject the connection with, the slot has to be released when nil.
+func (l *connLimiter) admit(ip string, stop <-chan struct{}) error {
+	l.mu.Lock()
+	if l.cfg.MaxConnectionsPerIP > 0 && l.perIP[ip] >= l.cfg.MaxConnectionsPerIP {
+		l.mu.Unlock()
+		return protocol.NewError(protocol.CodeTooManyConnections, "too many connections from %s", ip).WithRetryAfter(l.cfg.RetryAfter)
+	}
+	l.perIP[ip]++
+	l.mu.Unlock()
+
+	if l.slots == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if l.cfg.Policy == WaitWhenFull {
+		select {
+		case l.slots <- struct{}{}:
+			return nil
+		case <-stop:
+		}
+	}
+	l.releaseIP(ip)
+	return protocol.NewError(protocol.CodeTooManyConnections, "server is at its connection limit").WithRetryAfter(l.cfg.RetryAfter)
+}
+
+// release - frees the slot taken by admit
+func (l *connLimiter) release(ip string) {
+	if l.slots != nil {
+		<-l.slots
+	}
+	l.releaseIP(ip)
+}
+
+func (l *connLimiter) releaseIP(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
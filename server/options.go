@@ -0,0 +1,108 @@
+package server
+
+import (
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/challenge"
+	"github.com/Lockwarr/WordOfWisdom/internal/pow"
+	"github.com/Lockwarr/WordOfWisdom/internal/quotes"
+	"github.com/Lockwarr/WordOfWisdom/internal/reputation"
+)
+
+// Option - configures optional behaviour of the tcp server
+type Option func(*tcpServer)
+
+// WithDifficulty - overrides the adaptive proof of work difficulty configuration
+func WithDifficulty(cfg DifficultyConfig) Option {
+	return func(s *tcpServer) {
+		s.difficultyCfg = cfg
+	}
+}
+
+// WithReputation - uses the given tracker to raise the difficulty for misbehaving clients
+func WithReputation(tracker *reputation.Tracker) Option {
+	return func(s *tcpServer) {
+		s.reputation = tracker
+	}
+}
+
+// WithAlgorithm - issues challenges with the given proof of work algorithm instead of SHA-1 hashcash.
+// The DifficultyConfig bounds are scaled to the algorithm's unit, so switching algorithms keeps the expected work.
+func WithAlgorithm(alg pow.Algorithm) Option {
+	return func(s *tcpServer) {
+		s.algorithm = alg
+	}
+}
+
+// WithMaxFrameSize - limits the size of a single message read from a client
+func WithMaxFrameSize(size int) Option {
+	return func(s *tcpServer) {
+		s.maxFrameSize = size
+	}
+}
+
+// WithIdentity - name the server introduces itself with during the handshake
+func WithIdentity(identity string) Option {
+	return func(s *tcpServer) {
+		s.identity = identity
+	}
+}
+
+// WithStatelessChallenges - signs challenges instead of storing them, so any server sharing the signer's secret
+// can verify them and the repository only tracks spent solutions. Hashcash tokens are not accepted in this mode.
+func WithStatelessChallenges(signer *challenge.Signer) Option {
+	return func(s *tcpServer) {
+		s.signer = signer
+	}
+}
+
+// WithBinding - ties issued challenges to the client's address and/or connection, instead of DefaultBinding
+func WithBinding(binding Binding) Option {
+	return func(s *tcpServer) {
+		s.binding = binding
+	}
+}
+
+// WithShutdownTimeout - how long a stopping server waits for clients to finish their exchange, instead of DefaultShutdownTimeout
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(s *tcpServer) {
+		s.shutdownTimeout = timeout
+	}
+}
+
+// WithConnectionLimits - caps the open connections globally and per IP address, instead of DefaultLimitsConfig
+func WithConnectionLimits(cfg LimitsConfig) Option {
+	return func(s *tcpServer) {
+		s.limiter = newConnLimiter(cfg)
+	}
+}
+
+// WithTimeouts - bounds how long a connection can go without progress, instead of DefaultTimeoutsConfig
+func WithTimeouts(cfg TimeoutsConfig) Option {
+	return func(s *tcpServer) {
+		s.timeouts = cfg
+	}
+}
+
+// WithSolveWindow - how long an issued challenge can be redeemed, instead of DefaultSolveWindow.
+// Signed challenges are capped at the signer's MaxAge as well. The Solve timeout is raised to the window when shorter.
+func WithSolveWindow(window time.Duration) Option {
+	return func(s *tcpServer) {
+		s.solveWindow = window
+	}
+}
+
+// WithSelector - picks the quote served out of the ones matching a request, instead of uniformly at random
+func WithSelector(selector quotes.Selector) Option {
+	return func(s *tcpServer) {
+		s.selector = selector
+	}
+}
+
+// WithMaxPendingChallenges - how many unredeemed challenges a client subnet can hold at once, instead of
+// DefaultMaxPendingChallenges. Zero lifts the cap. Signed challenges aren't stored and don't count.
+func WithMaxPendingChallenges(max int) Option {
+	return func(s *tcpServer) {
+		s.maxPendingChallenges = max
+	}
+}
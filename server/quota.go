@@ -0,0 +1,89 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultMaxPendingChallenges - how many unredeemed challenges a client subnet can hold at once
+const DefaultMaxPendingChallenges = 100
+
+// maxQuotaEntries - subnets tracked before the ones without pending challenges are pruned
+const maxQuotaEntries = 100000
+
+// challengeQuota - caps the stored challenges pending per client subnet. Requesting a challenge costs no work, without
+// the cap a single client could fill the repository and get every other client's pending challenge evicted.
+type challengeQuota struct {
+	max int
+
+	mu sync.Mutex
+	// pending are the expiry times of the subnet's unredeemed challenges, oldest first
+	pending map[string][]time.Time
+}
+
+func newChallengeQuota(max int) *challengeQuota {
+	return &challengeQuota{max: max, pending: map[string][]time.Time{}}
+}
+
+// take - reserves a challenge expiring at expiresAt for the subnet. When the subnet already holds max pending
+// challenges it returns false and how long until the oldest of them expires.
+func (q *challengeQuota) take(subnet string, now, expiresAt time.Time) (bool, time.Duration) {
+	if q.max <= 0 {
+		return true, 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pending := unexpired(q.pending[subnet], now)
+	if len(pending) >= q.max {
+		q.pending[subnet] = pending
+		return false, pending[0].Sub(now)
+	}
+	if len(q.pending) >= maxQuotaEntries {
+		q.prune(now)
+	}
+	q.pending[subnet] = append(pending, expiresAt)
+	return true, 0
+}
+
+// release - gives back the subnet's pending challenge expiring at expiresAt once it was redeemed
+func (q *challengeQuota) release(subnet string, expiresAt time.Time) {
+	if q.max <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pending := q.pending[subnet]
+	for i, e := range pending {
+		if !e.Equal(expiresAt) {
+			continue
+		}
+		if len(pending) == 1 {
+			delete(q.pending, subnet)
+			return
+		}
+		q.pending[subnet] = append(pending[:i], pending[i+1:]...)
+		return
+	}
+}
+
+// prune - forgets the subnets without unexpired challenges, must be called with q.mu held
+func (q *challengeQuota) prune(now time.Time) {
+	for subnet, pending := range q.pending {
+		if pending = unexpired(pending, now); len(pending) == 0 {
+			delete(q.pending, subnet)
+		} else {
+			q.pending[subnet] = pending
+		}
+	}
+}
+
+// unexpired - the expiry times after now, the times are ascending
+func unexpired(expiries []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(expiries) && !expiries[i].After(now) {
+		i++
+	}
+	return expiries[i:]
+}
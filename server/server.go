@@ -4,141 +4,543 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
 	"net"
-	"os"
-	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Lockwarr/WordOfWisdom/internal/challenge"
 	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
+	"github.com/Lockwarr/WordOfWisdom/internal/pow"
 	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
+	"github.com/Lockwarr/WordOfWisdom/internal/quotes"
 	"github.com/Lockwarr/WordOfWisdom/internal/repository"
+	"github.com/Lockwarr/WordOfWisdom/internal/reputation"
 )
 
-// Quotes - const array of quotes to respond on client's request
-var Quotes = []string{
-	"Quote 1",
-	"Quote 2",
-	"Quote 3",
-	"Quote 4",
-	"Quote 5",
-}
+// DefaultIdentity - name the server introduces itself with during the handshake
+const DefaultIdentity = "WordOfWisdom"
+
+// DefaultSolveWindow - how long an issued challenge can be redeemed
+const DefaultSolveWindow = 2 * time.Minute
+
+// DefaultShutdownTimeout - how long a stopping server waits for clients to finish their exchange
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ErrServerClosed - returned by Start once the server was stopped
+var ErrServerClosed = errors.New("server closed")
+
+const (
+	// minAcceptDelay and maxAcceptDelay bound the backoff after temporary accept errors, e.g. running out of file descriptors
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+	// rejectWriteTimeout - a rejected client that doesn't read must not stall the accept loop
+	rejectWriteTimeout = 100 * time.Millisecond
+	// hangUpTimeout and hangUpLimit bound how long and how much unread input is discarded after an error
+	hangUpTimeout = time.Second
+	hangUpLimit   = 1 << 20
+)
+
+const (
+	// maxIssueAttempts - how often a colliding challenge indicator is picked again
+	maxIssueAttempts = 5
+	// spentGracePeriod - spent stateless challenges are remembered this much past their max age, covering clock skew
+	spentGracePeriod = time.Minute
+)
 
 type Server interface {
-	Start(context.Context)
+	// Start - serves connections until the context is cancelled or Stop is called, then drains them
+	Start(context.Context) error
+	// Addr - waits until Start is listening and returns the bound address, e.g. the port picked for port 0.
+	// Returns nil when Start failed to listen.
+	Addr() net.Addr
 	ProcessRequest(context.Context, string, string) (*protocol.Message, error)
+	// Stop - makes Start stop accepting connections and drain the open ones, it doesn't wait for Start to return
 	Stop()
 }
 
 type tcpServer struct {
-	port string
-	host string
-	stop chan bool
-	repo repository.Repository
+	port     string
+	host     string
+	stop     chan struct{}
+	stopOnce sync.Once
+	// listening is closed once Start bound addr or failed to
+	listening chan struct{}
+	addr      net.Addr
+	repo      repository.Repository
+	quotes    quotes.Store
+	// difficulty is created from difficultyCfg once the options picked the algorithm
+	difficultyCfg DifficultyConfig
+	difficulty    *DifficultyController
+	reputation    *reputation.Tracker
+	algorithm     pow.Algorithm
+	// maxFrameSize limits the size of a single message read from a client
+	maxFrameSize int
+	// identity is sent to clients during the handshake
+	identity string
+	// signer makes challenges stateless when set, the repository then only tracks spent solutions
+	signer *challenge.Signer
+	// binding is what issued challenges are tied to
+	binding Binding
+	// solveWindow is how long an issued challenge can be redeemed
+	solveWindow time.Duration
+	// shutdownTimeout is how long Start waits for open connections once stopped
+	shutdownTimeout time.Duration
+	// limiter caps the open connections
+	limiter *connLimiter
+	// timeouts bound how long a connection can go without progress
+	timeouts TimeoutsConfig
+	// selector picks the quote served out of the ones matching a request
+	selector quotes.Selector
+	// quota caps the stored challenges pending per client subnet, maxPendingChallenges is its size
+	maxPendingChallenges int
+	quota                *challengeQuota
+
+	// conns are the open connections a shutdown has to drain, handlers is done once all of them are closed
+	connsMu  sync.Mutex
+	conns    map[net.Conn]*connState
+	handlers sync.WaitGroup
+}
+
+// connState - tracks whether a connection can be closed without dropping a client mid exchange
+type connState struct {
+	// busy is 1 from reading a request until the exchange is complete, i.e. while the client solves its challenge
+	busy int32
 }
 
-// NewTCPServer - creates a new TCP server
-func NewTCPServer(host, port string, repo repository.Repository) Server {
-	return &tcpServer{
-		port: port,
-		host: host,
-		repo: repo,
-		stop: make(chan bool),
+// NewTCPServer - creates a new TCP server serving the quotes of the store
+func NewTCPServer(host, port string, repo repository.Repository, store quotes.Store, opts ...Option) Server {
+	s := &tcpServer{
+		port:                 port,
+		host:                 host,
+		repo:                 repo,
+		quotes:               store,
+		stop:                 make(chan struct{}),
+		listening:            make(chan struct{}),
+		difficultyCfg:        DefaultDifficultyConfig(),
+		reputation:           reputation.NewTracker(reputation.DefaultConfig()),
+		algorithm:            pow.NewSHA1Hashcash(),
+		maxFrameSize:         protocol.DefaultMaxFrameSize,
+		identity:             DefaultIdentity,
+		binding:              DefaultBinding,
+		solveWindow:          DefaultSolveWindow,
+		shutdownTimeout:      DefaultShutdownTimeout,
+		limiter:              newConnLimiter(DefaultLimitsConfig()),
+		timeouts:             DefaultTimeoutsConfig(),
+		selector:             quotes.NewUniformSelector(rand.New(rand.NewSource(time.Now().UnixNano()))),
+		maxPendingChallenges: DefaultMaxPendingChallenges,
+		conns:                map[net.Conn]*connState{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// the connection waits for the solution as long as the challenge can be redeemed
+	if s.timeouts.Solve > 0 && s.timeouts.Solve < s.solveWindow {
+		s.timeouts.Solve = s.solveWindow
 	}
+	s.difficulty = NewDifficultyController(s.difficultyCfg.scaled(s.algorithm))
+	s.quota = newChallengeQuota(s.maxPendingChallenges)
+	return s
 }
 
-// Start - starts the server
-func (s *tcpServer) Start(ctx context.Context) {
+// Start - starts the server and blocks until it's stopped.
+// Stopping closes the listener, lets every connection finish its current exchange and force closes the ones
+// still open after the shutdown timeout. Returns ErrServerClosed once the connections are drained,
+// or the error that made listening or accepting fail.
+func (s *tcpServer) Start(ctx context.Context) error {
 	// Listen for incoming connections.
 	l, err := net.Listen("tcp", s.host+":"+s.port)
 	if err != nil {
-		log.Println("Error listening:", err.Error())
-		os.Exit(1)
+		close(s.listening)
+		return fmt.Errorf("err listen: %w", err)
 	}
+	s.addr = l.Addr()
+	close(s.listening)
 
 	// Close the listener when the application closes.
 	defer l.Close()
 	log.Println("Listening on ", l.Addr().String())
 
+	// connections outlive ctx while draining, a force close cancels the requests they are still processing
+	connCtx, cancelConns := context.WithCancel(context.Background())
+	defer cancelConns()
+
 	go func() {
-		// blocks until we receive on stop channel
-		<-s.stop
+		// blocks until we're stopped either way
+		select {
+		case <-ctx.Done():
+			s.Stop()
+		case <-s.stop:
+		}
 		log.Println("Stopping server")
-		defer l.Close()
-		close(s.stop)
+		l.Close()
 	}()
 
+	var acceptDelay time.Duration
 	for {
 		// Listen for an incoming connection.
 		conn, err := l.Accept()
 		if err != nil {
+			if s.stopping() {
+				return s.drain(cancelConns)
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Temporary() {
+				// back off like net/http does, the condition usually clears once connections are closed
+				if acceptDelay == 0 {
+					acceptDelay = minAcceptDelay
+				} else if acceptDelay *= 2; acceptDelay > maxAcceptDelay {
+					acceptDelay = maxAcceptDelay
+				}
+				log.Printf("Error accepting: %v; retrying in %s", err, acceptDelay)
+				s.sleep(acceptDelay)
+				continue
+			}
+			// the open connections are still drained before giving up
 			log.Println("Error accepting: ", err.Error())
-			os.Exit(1)
+			s.Stop()
+			if drainErr := s.drain(cancelConns); drainErr != ErrServerClosed {
+				log.Println(drainErr)
+			}
+			return fmt.Errorf("err accept: %w", err)
+		}
+		acceptDelay = 0
+
+		ip := clientIP(conn.RemoteAddr().String())
+		if err := s.limiter.admit(ip, s.stop); err != nil {
+			s.reject(conn, err)
+			continue
 		}
 		// Handle connections in a new goroutine.
-		go s.handleConnection(ctx, conn)
+		state := s.track(conn)
+		go func() {
+			defer s.limiter.release(ip)
+			defer s.untrack(conn)
+			s.handleConnection(connCtx, conn, state)
+		}()
+	}
+}
+
+// reject - tells a client over the connection limits why it's turned away and closes the connection.
+// The client hasn't picked an encoding yet, so the error is sent as json.
+func (s *tcpServer) reject(conn net.Conn, err error) {
+	defer conn.Close()
+	fmt.Printf("rejected client %s: %v\n", conn.RemoteAddr(), err)
 
+	_ = conn.SetWriteDeadline(time.Now().Add(rejectWriteTimeout))
+	encoder, encErr := protocol.NewEncoder(protocol.EncodingJSON, conn)
+	if encErr != nil {
+		fmt.Println("err create codec:", encErr)
+		return
+	}
+	s.sendError(encoder, err)
+}
+
+// Addr - waits until Start is listening and returns the bound address, nil when listening failed.
+// Blocks forever when Start is never called.
+func (s *tcpServer) Addr() net.Addr {
+	<-s.listening
+	return s.addr
+}
+
+// sleep - waits for the duration unless the server is stopped first
+func (s *tcpServer) sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-s.stop:
 	}
 }
 
 // Stop sends a stop signal to the server
 func (s *tcpServer) Stop() {
-	s.stop <- true
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *tcpServer) stopping() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *tcpServer) track(conn net.Conn) *connState {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	state := &connState{}
+	s.conns[conn] = state
+	s.handlers.Add(1)
+	return state
+}
+
+func (s *tcpServer) untrack(conn net.Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	delete(s.conns, conn)
+	s.handlers.Done()
+}
+
+// drain - waits for the open connections to finish their exchange. Idle ones are interrupted right away,
+// the ones still open after the shutdown timeout are closed and their requests cancelled.
+func (s *tcpServer) drain(cancelConns context.CancelFunc) error {
+	s.connsMu.Lock()
+	for conn, state := range s.conns {
+		// a busy handler checks for the stop itself once its exchange is complete
+		if atomic.LoadInt32(&state.busy) == 0 {
+			_ = conn.SetReadDeadline(time.Now())
+		}
+	}
+	s.connsMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(drained)
+	}()
+	timer := time.NewTimer(s.shutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		return ErrServerClosed
+	case <-timer.C:
+	}
+
+	s.connsMu.Lock()
+	stragglers := len(s.conns)
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connsMu.Unlock()
+	cancelConns()
+	<-drained
+	return fmt.Errorf("%w, force closed %d connections still open after %s", ErrServerClosed, stragglers, s.shutdownTimeout)
 }
 
-func (s *tcpServer) handleConnection(ctx context.Context, conn net.Conn) {
+func (s *tcpServer) handleConnection(ctx context.Context, conn net.Conn, state *connState) {
 	fmt.Println("new client:", conn.RemoteAddr())
 	defer conn.Close()
 
+	s.difficulty.ConnectionOpened()
+	defer s.difficulty.ConnectionClosed()
+
+	clientDetails := conn.RemoteAddr().String()
+	// challenges bound to the session can only be redeemed on this connection
+	session, err := challenge.NewNonce()
+	if err != nil {
+		fmt.Println("err generate session:", err)
+		return
+	}
+	ctx = ContextWithSession(ctx, session)
 	reader := bufio.NewReader(conn)
+	writer := &timeoutWriter{conn: conn, timeout: s.timeouts.Write}
 
-	for {
-		req, err := reader.ReadString('\n')
+	// errors are sent as json until the client picked an encoding
+	var decoder protocol.Decoder
+	encoder, err := protocol.NewEncoder(protocol.EncodingJSON, writer)
+	if err != nil {
+		fmt.Println("err create codec:", err)
+		return
+	}
+	// what the server waits for decides how long it waits
+	waitFor, timeout := "first message", s.timeouts.Handshake
+
+	for first := true; ; first = false {
+		err := s.awaitMessage(conn, reader, state, waitFor, timeout)
+		if err == nil && first {
+			// the client picks the encoding with the first byte it sends, a Hello can switch it afterwards
+			encoding, err := protocol.DetectEncoding(reader)
+			if err == nil {
+				decoder, encoder, err = s.newCodec(encoding, reader, writer)
+			}
+			if err != nil {
+				fmt.Println("err create codec:", err)
+				return
+			}
+		}
+		var req *protocol.Message
+		if err == nil {
+			req, err = decoder.Decode()
+		}
 		if err != nil {
 			fmt.Println("err read connection:", err)
+			// idle connections are interrupted by the shutdown, that's no fault of the client
+			if errors.Is(err, io.EOF) || s.stopping() {
+				return
+			}
+			var payload *protocol.ErrorPayload
+			var netErr net.Error
+			switch {
+			case errors.As(err, &payload):
+			case errors.As(err, &netErr) && netErr.Timeout():
+				err = protocol.NewError(protocol.CodeTimeout, "message not completed within %s", s.timeouts.Read)
+			default:
+				s.reputation.Record(clientDetails, reputation.MalformedMessage)
+				err = protocol.NewError(protocol.CodeMalformedMessage, "%v", err)
+			}
+			s.sendError(encoder, err)
+			hangUp(conn)
 			return
 		}
-		msg, err := s.ProcessRequest(ctx, req, conn.RemoteAddr().String())
+
+		// clients that don't start with a Hello speak the original json protocol
+		if first && req.Type == protocol.Hello {
+			negotiated, err := s.handshake(req, encoder, clientDetails)
+			if err != nil {
+				s.reputation.Record(clientDetails, reputation.MalformedMessage)
+				fmt.Println("err handshake:", err)
+				hangUp(conn)
+				return
+			}
+			decoder, encoder, err = s.newCodec(negotiated.Encoding, reader, writer)
+			if err != nil {
+				fmt.Println("err create codec:", err)
+				return
+			}
+			ctx = ContextWithProtocolVersion(ctx, negotiated.Version)
+			waitFor, timeout = "request", s.timeouts.Idle
+			continue
+		}
+
+		atomic.StoreInt32(&state.busy, 1)
+		start := time.Now()
+		msg, err := s.processMessage(ctx, req, clientDetails)
+		s.difficulty.ObserveRequest(time.Since(start))
 		if err != nil {
 			fmt.Println("err process request:", err)
+			s.sendError(encoder, err)
+			hangUp(conn)
 			return
 		}
 		if msg != nil {
-			err := sendMsg(*msg, conn)
+			err := encoder.Encode(*msg)
 			if err != nil {
+				// e.g. the write timeout, a client that doesn't read won't read an error either
 				fmt.Println("err send message:", err)
+				return
 			}
 		}
+
+		// a client that got a challenge is left to solve it, otherwise the exchange is complete
+		if msg != nil && msg.Type == protocol.ChallengeResponse {
+			waitFor, timeout = "solution", s.timeouts.Solve
+			continue
+		}
+		atomic.StoreInt32(&state.busy, 0)
+		// after the store, so either this or the drain notices the other
+		if s.stopping() {
+			return
+		}
+		waitFor, timeout = "request", s.timeouts.Idle
 	}
 }
 
-// ProcessRequest handles incoming requests.
+// hangUp - half closes the connection after an error was sent and discards what the client still sends, e.g. the rest
+// of an oversized message. Closing with unread input resets the connection, which can drop the error before it's read.
+func hangUp(conn net.Conn) {
+	if closer, ok := conn.(interface{ CloseWrite() error }); ok {
+		if err := closer.CloseWrite(); err != nil {
+			return
+		}
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(hangUpTimeout))
+	_, _ = io.Copy(io.Discard, io.LimitReader(conn, hangUpLimit))
+}
+
+// newCodec - creates the decoder and encoder for the connection
+func (s *tcpServer) newCodec(encoding protocol.Encoding, reader *bufio.Reader, writer io.Writer) (protocol.Decoder, protocol.Encoder, error) {
+	decoder, err := protocol.NewDecoder(encoding, reader, s.maxFrameSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoder, err := protocol.NewEncoder(encoding, writer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decoder, encoder, nil
+}
+
+// handshake - negotiates the protocol version and features with the client and responds with the outcome
+func (s *tcpServer) handshake(req *protocol.Message, encoder protocol.Encoder, clientDetails string) (protocol.HelloResponsePayload, error) {
+	var resp protocol.HelloResponsePayload
+	hello, err := protocol.ParseHello(req)
+	if err == nil {
+		resp, err = protocol.Negotiate(hello, protocol.HelloPayload{
+			Versions:    protocol.SupportedVersions,
+			Encodings:   []protocol.Encoding{protocol.EncodingJSON, protocol.EncodingBinary},
+			Algorithms:  []string{s.algorithm.Name()},
+			Compression: []string{protocol.CompressionNone},
+			Identity:    s.identity,
+		})
+	}
+	if err != nil {
+		s.sendError(encoder, err)
+		return resp, err
+	}
+	if err := encoder.Encode(protocol.NewHelloResponseMessage(resp)); err != nil {
+		return resp, err
+	}
+	fmt.Printf("client %s (%s) negotiated protocol v%d over %s\n", clientDetails, hello.Identity, resp.Version, resp.Encoding)
+	return resp, nil
+}
+
+// sendError - tells the client why its request was rejected before the connection is closed,
+// errors without a protocol.ErrorPayload in their chain are reported as internal so no details leak
+func (s *tcpServer) sendError(encoder protocol.Encoder, err error) {
+	var payload *protocol.ErrorPayload
+	if !errors.As(err, &payload) {
+		payload = protocol.NewError(protocol.CodeInternal, "internal server error")
+	}
+	if err := encoder.Encode(protocol.NewErrorMessage(payload)); err != nil {
+		fmt.Println("err send error:", err)
+	}
+}
+
+// ProcessRequest handles incoming json encoded requests.
 func (s *tcpServer) ProcessRequest(ctx context.Context, message, clientDetails string) (*protocol.Message, error) {
 	parsedMessage, err := protocol.ParseMessage([]byte(message))
 	if err != nil {
 		log.Println("Error parsing:", err.Error())
+		s.reputation.ObserveRequest(clientDetails)
+		s.reputation.Record(clientDetails, reputation.MalformedMessage)
+		return nil, protocol.NewError(protocol.CodeMalformedMessage, "err parse message: %v", err)
 	}
+	return s.processMessage(ctx, parsedMessage, clientDetails)
+}
+
+// processMessage handles a decoded request.
+func (s *tcpServer) processMessage(ctx context.Context, parsedMessage *protocol.Message, clientDetails string) (*protocol.Message, error) {
+	s.reputation.ObserveRequest(clientDetails)
 
 	switch parsedMessage.Type {
 	case protocol.ChallengeRequest:
 		log.Println("Challenge request received")
-		indicator := rand.Intn(200000)
-		rand := strconv.Itoa(indicator)
-		stamp := hashcash.Stamp{
-			Version:    1,
-			ZerosCount: 5,
-			Date:       time.Now().Unix(),
-			Resource:   parsedMessage.Data,
-			Rand:       rand,
-			Counter:    0,
-		}
-
-		err := s.repo.AddIndicator(ctx, int64(indicator))
+		// the penalty is counted in hex zeros like the difficulty bounds
+		zerosCount := s.difficulty.Difficulty() + s.reputation.Penalty(clientDetails)*s.algorithm.DifficultyPerHexZero()
+		binding := s.bindingOf(ctx, clientDetails)
+		var stamp hashcash.Stamp
+		var err error
+		if s.signer != nil {
+			stamp, err = s.signChallenge(parsedMessage.Data, zerosCount, binding)
+		} else {
+			stamp, err = s.storeChallenge(ctx, parsedMessage.Data, zerosCount, binding, clientDetails)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("Error adding indicator: %w", err)
+			return nil, err
 		}
+		// rounded up, the client is better off a little early than late
+		stamp.SolveWindow = int((s.solveWindow + time.Second - 1) / time.Second)
 
 		marshaledStamp, err := json.Marshal(stamp)
 		if err != nil {
@@ -152,54 +554,332 @@ func (s *tcpServer) ProcessRequest(ctx context.Context, message, clientDetails s
 		return &respMsg, nil
 	case protocol.QuoteRequest:
 		fmt.Printf("client %s requests quote %s\n", clientDetails, parsedMessage.Data)
+		// standard Hashcash stamps, e.g. minted by the hashcash tool, are accepted next to json encoded ones
+		if hashcash.IsToken(parsedMessage.Data) {
+			return s.processToken(ctx, parsedMessage.Data, clientDetails)
+		}
+
 		// parse client's solution
 		var stamp hashcash.Stamp
 		err := json.Unmarshal([]byte(parsedMessage.Data), &stamp)
 		if err != nil {
-			return nil, fmt.Errorf("err unmarshal hashcash: %w", err)
+			s.reputation.Record(clientDetails, reputation.MalformedMessage)
+			return nil, protocol.NewError(protocol.CodeMalformedMessage, "err unmarshal hashcash: %v", err)
+		}
+		filter, err := protocol.ParseQuoteFilter(parsedMessage)
+		if err != nil {
+			s.reputation.Record(clientDetails, reputation.MalformedMessage)
+			return nil, err
+		}
+
+		// the challenge has to be issued by this server in the past
+		entry, key, err := s.findChallenge(ctx, stamp, clientDetails)
+		if err != nil {
+			return nil, err
+		}
+
+		// the client must not lower the difficulty the challenge was minted with
+		if stamp.ZerosCount < entry.Difficulty {
+			s.reputation.Record(clientDetails, reputation.FailedSolution)
+			return nil, protocol.NewError(protocol.CodeInvalidSolution, "stamp difficulty %d is below issued %d", stamp.ZerosCount, entry.Difficulty)
+		}
+
+		// nor switch to a version that counts the zeros differently
+		if stamp.Version != entry.Version {
+			s.reputation.Record(clientDetails, reputation.FailedSolution)
+			return nil, protocol.NewError(protocol.CodeInvalidSolution, "stamp version %d doesn't match issued %d", stamp.Version, entry.Version)
+		}
+
+		// the stamp must be solved with the algorithm the server issued it for
+		alg, err := pow.Lookup(stamp.Algorithm)
+		if err != nil || alg.Name() != s.algorithm.Name() {
+			s.reputation.Record(clientDetails, reputation.FailedSolution)
+			return nil, protocol.NewError(protocol.CodeInvalidSolution, "stamp algorithm %q doesn't match issued %q", stamp.Algorithm, s.algorithm.Name())
 		}
 
 		// validate hashcash params
-		if !stamp.ValidStamp(ctx, stamp, s.repo) {
-			return nil, fmt.Errorf("invalid hashcash %w", err)
+		if err := s.checkExpiry(entry, clientDetails); err != nil {
+			return nil, err
+		}
+		if !s.algorithm.Verify(stamp) {
+			s.reputation.Record(clientDetails, reputation.FailedSolution)
+			return nil, protocol.NewError(protocol.CodeInvalidSolution, "invalid hashcash")
 		}
 
-		randValue, err := strconv.Atoi(stamp.Rand)
+		// picked before the challenge is redeemed, so a filter matching nothing doesn't cost the solution
+		quote, err := s.pickQuote(filter, clientDetails)
 		if err != nil {
-			return nil, fmt.Errorf("err decode rand: %w", err)
+			return nil, err
+		}
+
+		// prevent duplicated request with same hashcash value
+		if err := s.redeemChallenge(ctx, key, clientDetails); err != nil {
+			return nil, err
 		}
 
-		// if rand exists in inmemory db, it means, that hashcash is valid and really challenged by this server in past
-		_, err = s.repo.GetIndicator(ctx, int64(randValue))
+		fmt.Printf("client %s succesfully computed hashcash %s\n", clientDetails, parsedMessage.Data)
+
+		// respond to client
+		return s.quoteResponse(ctx, quote), nil
+	default:
+		s.reputation.Record(clientDetails, reputation.MalformedMessage)
+		return nil, protocol.NewError(protocol.CodeUnknownType, "unknown request type %d", parsedMessage.Type)
+	}
+}
+
+// processToken - validates a standard Hashcash v1/v0 solution.
+// The token's resource has to be the rand of the issued challenge, e.g. `hashcash -m -b <bits> <rand>`
+func (s *tcpServer) processToken(ctx context.Context, data, clientDetails string) (*protocol.Message, error) {
+	token, err := hashcash.ParseToken(data)
+	if err != nil {
+		s.reputation.Record(clientDetails, reputation.MalformedMessage)
+		return nil, protocol.NewError(protocol.CodeMalformedMessage, "err parse hashcash token: %v", err)
+	}
+
+	// a token carries only the rand of the challenge, which isn't enough to check a signed challenge
+	if s.signer != nil {
+		return nil, protocol.NewError(protocol.CodeInvalidSolution, "hashcash tokens are not accepted for stateless challenges")
+	}
+
+	// Hashcash tokens are always SHA-1
+	if s.algorithm.Name() != pow.SHA1Hashcash {
+		s.reputation.Record(clientDetails, reputation.FailedSolution)
+		return nil, protocol.NewError(protocol.CodeInvalidSolution, "hashcash token doesn't match issued algorithm %q", s.algorithm.Name())
+	}
+
+	entry, err := s.lookupIndicator(ctx, token.Resource, clientDetails)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBinding(entry, s.bindingOf(ctx, clientDetails), clientDetails); err != nil {
+		return nil, err
+	}
+
+	requiredBits := entry.Difficulty
+	if entry.Version != hashcash.VersionZeroBits {
+		requiredBits *= 4
+	}
+	if err := s.checkExpiry(entry, clientDetails); err != nil {
+		return nil, err
+	}
+	if !token.Solves(requiredBits) {
+		s.reputation.Record(clientDetails, reputation.FailedSolution)
+		return nil, protocol.NewError(protocol.CodeInvalidSolution, "invalid hashcash token")
+	}
+
+	// tokens have no room for a filter
+	quote, err := s.pickQuote(protocol.QuoteFilter{}, clientDetails)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.consumeIndicator(ctx, token.Resource, clientDetails); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("client %s succesfully computed hashcash %s\n", clientDetails, data)
+	return s.quoteResponse(ctx, quote), nil
+}
+
+// storeChallenge - issues a challenge that is remembered in the repository until it's redeemed
+func (s *tcpServer) storeChallenge(ctx context.Context, resource string, zerosCount int, binding, clientDetails string) (hashcash.Stamp, error) {
+	// signed challenges aren't stored, so only stored ones count against the quota
+	now := time.Now()
+	subnet, expiresAt := s.reputation.Key(clientDetails), now.Add(s.solveWindow)
+	if ok, retryAfter := s.quota.take(subnet, now, expiresAt); !ok {
+		s.reputation.Record(clientDetails, reputation.Burst)
+		return hashcash.Stamp{}, protocol.NewError(protocol.CodeRateLimited, "%d challenges are already pending", s.maxPendingChallenges).WithRetryAfter(retryAfter)
+	}
+	for attempt := 1; ; attempt++ {
+		nonce, err := challenge.NewNonce()
+		if err != nil {
+			return hashcash.Stamp{}, fmt.Errorf("err generate nonce: %w", err)
+		}
+		stamp := s.algorithm.NewChallenge(resource, nonce, zerosCount)
+		err = s.repo.AddIndicator(ctx, nonce, repository.Entry{
+			Difficulty: zerosCount,
+			Version:    stamp.Version,
+			Binding:    binding,
+			Subnet:     subnet,
+			// the quota entry is released by its expiry
+			CreatedAt: now,
+			TTL:       s.solveWindow,
+		})
+		// AddIndicator never hands the same nonce out twice, a collision just means drawing again
+		if errors.Is(err, repository.ErrIndicatorExists) && attempt < maxIssueAttempts {
+			continue
+		}
 		if err != nil {
-			return nil, fmt.Errorf("err get rand from cache: %w", err)
+			s.quota.release(subnet, expiresAt)
+			return stamp, fmt.Errorf("Error adding indicator: %w", err)
 		}
+		return stamp, nil
+	}
+}
 
-		if !stamp.IsHashSolved() {
-			return nil, fmt.Errorf("challenge is not solved")
+// signChallenge - issues a challenge that is verified by its signature instead of being remembered
+func (s *tcpServer) signChallenge(resource string, zerosCount int, binding string) (hashcash.Stamp, error) {
+	nonce, err := challenge.NewNonce()
+	if err != nil {
+		return hashcash.Stamp{}, fmt.Errorf("err generate nonce: %w", err)
+	}
+	stamp := s.algorithm.NewChallenge(resource, nonce, zerosCount)
+	return s.signer.Sign(stamp, binding), nil
+}
+
+// findChallenge - returns what the stamp's challenge was issued with and the key it is redeemed by
+func (s *tcpServer) findChallenge(ctx context.Context, stamp hashcash.Stamp, clientDetails string) (repository.Entry, string, error) {
+	binding := s.bindingOf(ctx, clientDetails)
+	if s.signer == nil {
+		entry, err := s.lookupIndicator(ctx, stamp.Rand, clientDetails)
+		if err == nil {
+			err = s.checkBinding(entry, binding, clientDetails)
 		}
+		return entry, stamp.Rand, err
+	}
 
-		//get random quote
-		fmt.Printf("client %s succesfully computed hashcash %s\n", clientDetails, parsedMessage.Data)
+	nonce, err := s.signer.Verify(stamp, binding)
+	if errors.Is(err, challenge.ErrBindingMismatch) {
+		s.reputation.Record(clientDetails, reputation.FailedSolution)
+		return repository.Entry{}, "", protocol.NewError(protocol.CodeBindingMismatch, "challenge was issued to another client")
+	}
+	if errors.Is(err, challenge.ErrExpired) {
+		s.reputation.Record(clientDetails, reputation.FailedSolution)
+		return repository.Entry{}, "", protocol.NewError(protocol.CodeChallengeExpired, "challenges have to be solved within %s", s.signer.MaxAge())
+	}
+	if err != nil {
+		s.reputation.Record(clientDetails, reputation.FailedSolution)
+		return repository.Entry{}, "", protocol.NewError(protocol.CodeInvalidSolution, "%v", err)
+	}
+	// the signature vouches for the difficulty, version and date the challenge was issued with
+	return repository.Entry{Difficulty: stamp.ZerosCount, Version: stamp.Version, CreatedAt: time.Unix(stamp.Date, 0)}, nonce, nil
+}
+
+// redeemChallenge - makes sure a challenge is only redeemed once
+func (s *tcpServer) redeemChallenge(ctx context.Context, key, clientDetails string) error {
+	if s.signer == nil {
+		return s.consumeIndicator(ctx, key, clientDetails)
+	}
+
+	// stateless challenges are remembered once spent, and only until they would have expired anyway
+	err := s.repo.AddIndicator(ctx, key, repository.Entry{TTL: s.signer.MaxAge() + spentGracePeriod})
+	if errors.Is(err, repository.ErrIndicatorExists) {
+		s.reputation.Record(clientDetails, reputation.Replay)
+		return protocol.NewError(protocol.CodeReplayDetected, "challenge was already redeemed")
+	}
+	if err != nil {
+		return fmt.Errorf("err mark challenge spent: %w", err)
+	}
+	return nil
+}
+
+// checkExpiry - rejects solutions that arrive after the solve window, counted from when the server issued the challenge.
+// The repository expires stored challenges as well, but e.g. redis only in whole seconds.
+func (s *tcpServer) checkExpiry(entry repository.Entry, clientDetails string) error {
+	if time.Since(entry.CreatedAt) > s.solveWindow {
+		s.reputation.Record(clientDetails, reputation.FailedSolution)
+		return protocol.NewError(protocol.CodeChallengeExpired, "challenges have to be solved within %s", s.solveWindow)
+	}
+	return nil
+}
+
+// checkBinding - rejects solutions of challenges that were issued to another client, e.g. solved by a farm on its behalf
+func (s *tcpServer) checkBinding(entry repository.Entry, binding, clientDetails string) error {
+	if entry.Binding != binding {
+		s.reputation.Record(clientDetails, reputation.FailedSolution)
+		return protocol.NewError(protocol.CodeBindingMismatch, "challenge was issued to another client")
+	}
+	return nil
+}
 
-		msg := protocol.Message{
-			Type: protocol.QuoteResponse,
-			Data: Quotes[rand.Intn(5)],
+// clientIP - strips the port, so a client reconnecting from the same address can redeem its challenges
+func clientIP(clientDetails string) string {
+	host, _, err := net.SplitHostPort(clientDetails)
+	if err != nil {
+		return clientDetails
+	}
+	return host
+}
+
+// lookupIndicator - finds the challenge a solution was computed for, a missing one was never issued or already redeemed
+func (s *tcpServer) lookupIndicator(ctx context.Context, indicator, clientDetails string) (repository.Entry, error) {
+	entry, err := s.repo.GetIndicator(ctx, indicator)
+	return entry, s.indicatorError(err, indicator, clientDetails)
+}
+
+// consumeIndicator - redeems the challenge, of concurrent submissions of the same solution only one gets through.
+// The subnet the challenge was issued to gets its pending challenge back, whoever redeems it.
+func (s *tcpServer) consumeIndicator(ctx context.Context, indicator, clientDetails string) error {
+	entry, err := s.repo.ConsumeIndicator(ctx, indicator)
+	if err != nil {
+		return s.indicatorError(err, indicator, clientDetails)
+	}
+	s.quota.release(entry.Subnet, entry.CreatedAt.Add(entry.TTL))
+	return nil
+}
+
+// indicatorError - tells the client why its challenge can't be redeemed
+func (s *tcpServer) indicatorError(err error, indicator, clientDetails string) error {
+	if errors.Is(err, repository.ErrIndicatorNotFound) {
+		s.reputation.Record(clientDetails, reputation.Replay)
+		return protocol.NewError(protocol.CodeReplayDetected, "challenge %q was not issued or already redeemed", indicator)
+	}
+	if errors.Is(err, repository.ErrIndicatorExpired) {
+		s.reputation.Record(clientDetails, reputation.FailedSolution)
+		return protocol.NewError(protocol.CodeChallengeExpired, "challenge %q expired", indicator)
+	}
+	if err != nil {
+		return fmt.Errorf("err get rand from cache: %w", err)
+	}
+	return nil
+}
+
+// pickQuote - picks one of the quotes of the store matching the filter with the server's selector.
+// Clients are told apart by their IP address, a client opens a new connection for every quote.
+func (s *tcpServer) pickQuote(filter protocol.QuoteFilter, clientDetails string) (quotes.Quote, error) {
+	matching := s.quotes.Find(quotes.Query{
+		ID:        filter.ID,
+		Tag:       filter.Tag,
+		Author:    filter.Author,
+		Languages: filter.Languages,
+	})
+	if len(matching) == 0 {
+		if filter.IsEmpty() {
+			return quotes.Quote{}, fmt.Errorf("err pick quote: %w", quotes.ErrNoQuotes)
 		}
+		return quotes.Quote{}, protocol.NewError(protocol.CodeQuoteNotFound, "no quote matches %+v", filter)
+	}
+	return s.selector.Select(clientIP(clientDetails), matching), nil
+}
 
-		// delete rand from cache to prevent duplicated request with same hashcash value
-		s.repo.RemoveIndicator(ctx, int64(randValue))
+// quoteResponse - the quote response, structured or plain text depending on the protocol version the client speaks
+func (s *tcpServer) quoteResponse(ctx context.Context, quote quotes.Quote) *protocol.Message {
+	msg := protocol.NewQuoteResponseMessage(protocolVersionFrom(ctx), quotePayload(quote))
+	return &msg
+}
 
-		// respond to client
-		return &msg, nil
-	default:
-		return nil, fmt.Errorf("unknown request received")
+// quotePayload - the quote as it's sent to clients
+func quotePayload(q quotes.Quote) protocol.QuotePayload {
+	return protocol.QuotePayload{
+		ID:       q.ID,
+		Text:     q.Text,
+		Author:   q.Author,
+		Source:   q.Source,
+		Tags:     q.Tags,
+		Language: q.Language,
 	}
 }
 
-// sendMsg - send protocol message to connection
-func sendMsg(msg protocol.Message, conn net.Conn) error {
-	msgStr := fmt.Sprintf("%s\n", msg.ToJsonString())
-	_, err := conn.Write([]byte(msgStr))
-	return err
+type versionKey struct{}
+
+// ContextWithProtocolVersion - marks the requests processed with the context as sent by a client speaking the given
+// protocol version, handleConnection sets the negotiated one. Requests without one are answered in version 1.
+func ContextWithProtocolVersion(ctx context.Context, version int) context.Context {
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+func protocolVersionFrom(ctx context.Context) int {
+	if version, ok := ctx.Value(versionKey{}).(int); ok {
+		return version
+	}
+	return 1
 }
@@ -1,26 +1,42 @@
 package server_test
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
 	"net"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/Lockwarr/WordOfWisdom/internal/challenge"
 	"github.com/Lockwarr/WordOfWisdom/internal/hashcash"
+	"github.com/Lockwarr/WordOfWisdom/internal/pow"
 	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
+	"github.com/Lockwarr/WordOfWisdom/internal/quotes"
 	"github.com/Lockwarr/WordOfWisdom/internal/repository"
+	"github.com/Lockwarr/WordOfWisdom/internal/reputation"
 	"github.com/Lockwarr/WordOfWisdom/server"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// serverAddr - address of the server shared by the connection tests
+var serverAddr string
+
 func TestMain(m *testing.M) {
 	repo := repository.NewInMemoryDB()
-	tcpSrvr := server.NewTCPServer("localhost", "8005", repo)
+	tcpSrvr := server.NewTCPServer("localhost", "0", repo, quotes.NewEmbeddedStore())
 	go tcpSrvr.Start(context.Background())
-	time.Sleep(time.Second)
+	serverAddr = tcpSrvr.Addr().String()
 
 	code := m.Run()
 	tcpSrvr.Stop()
@@ -30,7 +46,7 @@ func TestMain(m *testing.M) {
 func TestHandlingConnection(t *testing.T) {
 	// Arrange
 	// Start client
-	conn, err := net.Dial("tcp", ":8005")
+	conn, err := net.Dial("tcp", serverAddr)
 	assert.NoError(t, err)
 	defer conn.Close()
 
@@ -42,10 +58,93 @@ func TestHandlingConnection(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHandlingConnectionWithOversizedMessage(t *testing.T) {
+	// Arrange
+	conn, err := net.Dial("tcp", serverAddr)
+	assert.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// Act
+	// Send a line larger than the max frame size without ever ending it
+	_, err = conn.Write([]byte(strings.Repeat("a", 2*protocol.DefaultMaxFrameSize)))
+	assert.NoError(t, err)
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	_, eofErr := reader.ReadString('\n')
+
+	// Assert
+	// The server drops the connection instead of buffering the message, after telling the client why
+	msg, err := protocol.ParseMessage([]byte(line))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(protocol.ParseError(msg), protocol.ErrMalformedMessage))
+	assert.Equal(t, io.EOF, eofErr)
+}
+
+func TestHandlingConnectionWithMalformedMessage(t *testing.T) {
+	// Arrange
+	conn, err := net.Dial("tcp", serverAddr)
+	assert.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// Act
+	_, err = conn.Write([]byte("not json\n"))
+	assert.NoError(t, err)
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	_, eofErr := reader.ReadString('\n')
+
+	// Assert
+	// The client is told why before the connection is closed
+	msg, err := protocol.ParseMessage([]byte(line))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(protocol.ParseError(msg), protocol.ErrMalformedMessage))
+	assert.Equal(t, io.EOF, eofErr)
+}
+
+func TestHandlingConnectionWithHandshake(t *testing.T) {
+	// Arrange
+	conn, err := net.Dial("tcp", serverAddr)
+	assert.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	jsonEncoder, err := protocol.NewEncoder(protocol.EncodingJSON, conn)
+	assert.NoError(t, err)
+	jsonDecoder, err := protocol.NewDecoder(protocol.EncodingJSON, reader, 0)
+	assert.NoError(t, err)
+
+	// Act
+	err = jsonEncoder.Encode(protocol.NewHelloMessage(protocol.HelloPayload{
+		Versions:   []int{protocol.Version},
+		Encodings:  []protocol.Encoding{protocol.EncodingBinary},
+		Algorithms: []string{pow.SHA1Hashcash},
+	}))
+	assert.NoError(t, err)
+	resp, err := jsonDecoder.Decode()
+	assert.NoError(t, err)
+	negotiated, err := protocol.ParseHelloResponse(resp)
+	assert.NoError(t, err)
+
+	// After the handshake both sides switch to binary frames
+	binaryEncoder, err := protocol.NewEncoder(protocol.EncodingBinary, conn)
+	assert.NoError(t, err)
+	binaryDecoder, err := protocol.NewDecoder(protocol.EncodingBinary, reader, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, binaryEncoder.Encode(protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}))
+	challenge, err := binaryDecoder.Decode()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.EncodingBinary, negotiated.Encoding)
+	assert.Equal(t, server.DefaultIdentity, negotiated.Identity)
+	assert.Equal(t, protocol.ChallengeResponse, challenge.Type)
+}
+
 func TestProcessChallengeRequest(t *testing.T) {
 	// Arrange
 	repo := repository.NewInMemoryDB()
-	tcpServer := server.NewTCPServer("", "", repo)
+	tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore())
 	message := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
 	msgBytes, err := json.Marshal(message)
 	assert.NoError(t, err)
@@ -57,12 +156,17 @@ func TestProcessChallengeRequest(t *testing.T) {
 	// Assert
 	assert.NoError(t, err)
 	assert.Equal(t, protocol.ChallengeResponse, msg.Type)
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	assert.Len(t, stamp.Rand, 2*challenge.NonceSize)
+	_, err = repo.GetIndicator(context.Background(), stamp.Rand)
+	assert.NoError(t, err)
 }
 
 func TestProcessQuoteRequest(t *testing.T) {
 	// Arrange
 	repo := repository.NewInMemoryDB()
-	tcpServer := server.NewTCPServer("", "", repo)
+	tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore())
 	stamp := hashcash.Stamp{}
 
 	// We need to send a challenge request first so we can have an indicator entry in the repo
@@ -96,8 +200,8 @@ func TestProcessQuoteRequest(t *testing.T) {
 func TestProcessUnknownRequest(t *testing.T) {
 	// Arrange
 	repo := repository.NewInMemoryDB()
-	tcpServer := server.NewTCPServer("", "", repo)
-	message := protocol.Message{Type: 7, Data: "empty"}
+	tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore())
+	message := protocol.Message{Type: 42, Data: "empty"}
 	msgBytes, err := json.Marshal(message)
 	assert.NoError(t, err)
 
@@ -105,6 +209,890 @@ func TestProcessUnknownRequest(t *testing.T) {
 	// Request challenge
 	_, err = tcpServer.ProcessRequest(context.Background(), string(msgBytes), "testClient")
 
+	// Assert
+	assert.True(t, errors.Is(err, protocol.ErrUnknownType))
+}
+
+func TestFailedSolutionsRaiseClientDifficulty(t *testing.T) {
+	tests := []struct {
+		name        string
+		alg         pow.Algorithm
+		wantedExtra int
+	}{
+		{name: "hex zeros", alg: pow.NewSHA1Hashcash(), wantedExtra: 1},
+		{name: "bits", alg: pow.NewSHA1HashcashBits(), wantedExtra: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			cfg := reputation.DefaultConfig()
+			cfg.PointsPerZero = 1.5
+			tracker := reputation.NewTracker(cfg)
+			repo := repository.NewInMemoryDB()
+			tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore(), server.WithReputation(tracker), server.WithAlgorithm(tt.alg))
+			challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+
+			msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.0.1:1000")
+			assert.NoError(t, err)
+			var stamp hashcash.Stamp
+			assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+
+			// Act
+			// Submit the challenge unsolved
+			unsolvedStamp, err := json.Marshal(stamp)
+			assert.NoError(t, err)
+			quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(unsolvedStamp)}
+			_, err = tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "10.0.0.1:1000")
+			assert.Error(t, err)
+			msg, err = tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.0.1:1000")
+			assert.NoError(t, err)
+			var nextStamp hashcash.Stamp
+			assert.NoError(t, json.Unmarshal([]byte(msg.Data), &nextStamp))
+
+			// Assert
+			// one hex zero of extra work, 16 times the attempts in either unit
+			assert.Equal(t, stamp.ZerosCount+tt.wantedExtra, nextStamp.ZerosCount)
+			assert.Equal(t, 16*expectedAttempts(stamp), expectedAttempts(nextStamp))
+		})
+	}
+}
+
+func TestProcessQuoteRequestWithSHA256(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDB()
+	cfg := server.DefaultDifficultyConfig()
+	cfg.MinZeros, cfg.MaxZeros = 3, 3
+	tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore(), server.WithAlgorithm(pow.NewSHA256Hashcash()), server.WithDifficulty(cfg))
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "testClient")
+	assert.NoError(t, err)
+
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	assert.Equal(t, pow.SHA256Hashcash, stamp.Algorithm)
+	alg, err := pow.Lookup(stamp.Algorithm)
+	assert.NoError(t, err)
+	solvedStamp, err := alg.Solve(stamp, 0)
+	assert.NoError(t, err)
+	solvedStampMarshaled, err := json.Marshal(solvedStamp)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+
+	// Act
+	msg, err = tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "testClient")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.QuoteResponse, msg.Type)
+}
+
+func TestProcessQuoteRequestWithDifferentAlgorithm(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDB()
+	tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore(), server.WithAlgorithm(pow.NewArgon2id()))
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "testClient")
+	assert.NoError(t, err)
+
+	// Solve the argon2id challenge with cheap SHA-256 instead
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	stamp.Algorithm = pow.SHA256Hashcash
+	solvedStamp, err := pow.NewSHA256Hashcash().Solve(stamp, 0)
+	assert.NoError(t, err)
+	solvedStampMarshaled, err := json.Marshal(solvedStamp)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+
+	// Act
+	_, err = tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "testClient")
+
+	// Assert
+	assert.True(t, errors.Is(err, protocol.ErrInvalidSolution))
+}
+
+func TestProcessQuoteRequestWithChangedStampVersion(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDB()
+	tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore())
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "testClient")
+	assert.NoError(t, err)
+
+	// 5 leading zero bits are far cheaper than the 5 hex characters that were issued
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	assert.Equal(t, hashcash.VersionHexZeros, stamp.Version)
+	stamp.Version = hashcash.VersionZeroBits
+	solvedStamp, err := stamp.ComputeHashcash(0)
+	assert.NoError(t, err)
+	solvedStampMarshaled, err := json.Marshal(solvedStamp)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+
+	// Act
+	_, err = tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "testClient")
+
+	// Assert
+	assert.True(t, errors.Is(err, protocol.ErrInvalidSolution))
+}
+
+func TestProcessQuoteRequestWithHashcashToken(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDB()
+	tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore())
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "testClient")
+	assert.NoError(t, err)
+
+	// Mint a standard Hashcash v1 stamp over the challenge's rand, like `hashcash -m -b 20 <rand>` does
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	token, err := hashcash.MintToken(stamp.Rand, stamp.ZerosCount*4, nil, time.Now(), 0)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: token.String()}
+
+	// Act
+	msg, err = tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "testClient")
+	_, replayErr := tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "testClient")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.QuoteResponse, msg.Type)
+	assert.Error(t, replayErr)
+}
+
+func TestProcessQuoteRequestWithCheapHashcashToken(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDB()
+	tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore())
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "testClient")
+	assert.NoError(t, err)
+
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	token, err := hashcash.MintToken(stamp.Rand, stamp.ZerosCount, nil, time.Now(), 0)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: token.String()}
+
+	// Act
+	_, err = tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "testClient")
+
+	// Assert
+	assert.True(t, errors.Is(err, protocol.ErrInvalidSolution))
+}
+
+func TestProcessQuoteRequestWithStatelessChallenge(t *testing.T) {
+	// Arrange
+	signer, err := challenge.NewSigner(challenge.Config{Secret: []byte("shared secret")})
+	assert.NoError(t, err)
+	// challenges are issued and redeemed by two instances that only share the secret
+	issuer := server.NewTCPServer("", "", repository.NewInMemoryDB(), quotes.NewEmbeddedStore(), server.WithStatelessChallenges(signer))
+	spent := repository.NewInMemoryDB()
+	redeemer := server.NewTCPServer("", "", spent, quotes.NewEmbeddedStore(), server.WithStatelessChallenges(signer))
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	msg, err := issuer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.0.1:1000")
+	assert.NoError(t, err)
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	solvedStamp, err := stamp.ComputeHashcash(10000000)
+	assert.NoError(t, err)
+	solvedStampMarshaled, err := json.Marshal(solvedStamp)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+
+	// Act
+	quote, err := redeemer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "10.0.0.1:2000")
+	_, replayErr := redeemer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "10.0.0.1:2000")
+	_, stolenErr := issuer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "10.0.0.2:1000")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.QuoteResponse, quote.Type)
+	assert.True(t, errors.Is(replayErr, protocol.ErrReplayDetected))
+	assert.True(t, errors.Is(stolenErr, protocol.ErrBindingMismatch))
+}
+
+func TestProcessQuoteRequestWithForgedStatelessChallenge(t *testing.T) {
+	// Arrange
+	signer, err := challenge.NewSigner(challenge.DefaultConfig())
+	assert.NoError(t, err)
+	tcpServer := server.NewTCPServer("", "", repository.NewInMemoryDB(), quotes.NewEmbeddedStore(), server.WithStatelessChallenges(signer))
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "testClient")
+	assert.NoError(t, err)
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	// the client tries to get away with less work than it was asked for
+	stamp.ZerosCount = 1
+	solvedStamp, err := stamp.ComputeHashcash(10000000)
+	assert.NoError(t, err)
+	solvedStampMarshaled, err := json.Marshal(solvedStamp)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+
+	// Act
+	_, err = tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "testClient")
+
+	// Assert
+	assert.True(t, errors.Is(err, protocol.ErrInvalidSolution))
+}
+
+func TestProcessQuoteRequestRedeemedOnce(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDB()
+	tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore())
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.0.1:1000")
+	assert.NoError(t, err)
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	solvedStamp, err := stamp.ComputeHashcash(10000000)
+	assert.NoError(t, err)
+	solvedStampMarshaled, err := json.Marshal(solvedStamp)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+	var wg sync.WaitGroup
+	var redeemed, replayed int32
+
+	// Act
+	// the same solution submitted on many connections at once
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), fmt.Sprintf("10.0.0.1:%d", 2000+i))
+			switch {
+			case err == nil:
+				atomic.AddInt32(&redeemed, 1)
+			case errors.Is(err, protocol.ErrReplayDetected):
+				atomic.AddInt32(&replayed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Assert
+	assert.Equal(t, int32(1), redeemed)
+	assert.Equal(t, int32(19), replayed)
+}
+
+// solveChallenge - requests a challenge with the given context and client, and returns the quote request solving it
+func solveChallenge(t *testing.T, ctx context.Context, tcpServer server.Server, clientDetails string) protocol.Message {
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	msg, err := tcpServer.ProcessRequest(ctx, challengeRequest.ToJsonString(), clientDetails)
+	assert.NoError(t, err)
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	solvedStamp, err := stamp.ComputeHashcash(10000000)
+	assert.NoError(t, err)
+	solvedStampMarshaled, err := json.Marshal(solvedStamp)
+	assert.NoError(t, err)
+	return protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+}
+
+func TestProcessQuoteRequestBinding(t *testing.T) {
+	signer, err := challenge.NewSigner(challenge.DefaultConfig())
+	assert.NoError(t, err)
+	session := server.ContextWithSession(context.Background(), "session 1")
+	otherSession := server.ContextWithSession(context.Background(), "session 2")
+
+	tests := []struct {
+		name      string
+		opts      []server.Option
+		redeemCtx context.Context
+		redeemer  string
+		wantedErr error
+	}{
+		{name: "same address, new connection", redeemCtx: otherSession, redeemer: "10.0.0.1:2000"},
+		{name: "another address", redeemCtx: session, redeemer: "10.0.0.2:1000", wantedErr: protocol.ErrBindingMismatch},
+		{name: "another address, unbound", opts: []server.Option{server.WithBinding(server.BindNone)}, redeemCtx: otherSession, redeemer: "10.0.0.2:1000"},
+		{name: "same session", opts: []server.Option{server.WithBinding(server.BindSession)}, redeemCtx: session, redeemer: "10.0.0.2:1000"},
+		{name: "another session", opts: []server.Option{server.WithBinding(server.BindSession)}, redeemCtx: otherSession, redeemer: "10.0.0.1:1000", wantedErr: protocol.ErrBindingMismatch},
+		{name: "another session, stateless", opts: []server.Option{server.WithBinding(server.BindAddress | server.BindSession), server.WithStatelessChallenges(signer)}, redeemCtx: otherSession, redeemer: "10.0.0.1:1000", wantedErr: protocol.ErrBindingMismatch},
+		{name: "another address, stateless", opts: []server.Option{server.WithStatelessChallenges(signer)}, redeemCtx: session, redeemer: "10.0.0.2:1000", wantedErr: protocol.ErrBindingMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			tcpServer := server.NewTCPServer("", "", repository.NewInMemoryDB(), quotes.NewEmbeddedStore(), tt.opts...)
+			quoteRequest := solveChallenge(t, session, tcpServer, "10.0.0.1:1000")
+
+			// Act
+			msg, err := tcpServer.ProcessRequest(tt.redeemCtx, quoteRequest.ToJsonString(), tt.redeemer)
+
+			// Assert
+			if tt.wantedErr != nil {
+				assert.True(t, errors.Is(err, tt.wantedErr))
+				// the challenge is still there for the client it was issued to
+				_, err = tcpServer.ProcessRequest(session, quoteRequest.ToJsonString(), "10.0.0.1:1000")
+			}
+			assert.NoError(t, err)
+			if msg != nil {
+				assert.Equal(t, protocol.QuoteResponse, msg.Type)
+			}
+		})
+	}
+}
+
+func TestParseBinding(t *testing.T) {
+	// Act
+	both, err := server.ParseBinding("address, session")
+	none, noneErr := server.ParseBinding("none")
+	_, unknownErr := server.ParseBinding("cookie")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, server.BindAddress|server.BindSession, both)
+	assert.NoError(t, noneErr)
+	assert.Equal(t, server.BindNone, none)
+	assert.Error(t, unknownErr)
+}
+
+// startServer - starts a server on a free port, Start's result is sent on the channel
+func startServer(ctx context.Context, opts ...server.Option) (server.Server, <-chan error) {
+	tcpServer := server.NewTCPServer("localhost", "0", repository.NewInMemoryDB(), quotes.NewEmbeddedStore(), opts...)
+	started := make(chan error, 1)
+	go func() {
+		started <- tcpServer.Start(ctx)
+	}()
+	return tcpServer, started
+}
+
+// requestChallenge - opens a connection and requests a challenge on it
+func requestChallenge(t *testing.T, addr string) (net.Conn, *bufio.Reader, hashcash.Stamp) {
+	conn, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	_, err = conn.Write([]byte(challengeRequest.ToJsonString() + "\n"))
+	assert.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	msg, err := protocol.ParseMessage([]byte(line))
+	assert.NoError(t, err)
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+	return conn, reader, stamp
+}
+
+func TestStopDrainsConnections(t *testing.T) {
+	// Arrange
+	tcpServer, started := startServer(context.Background())
+	addr := tcpServer.Addr().String()
+	idle, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	defer idle.Close()
+	solving, reader, stamp := requestChallenge(t, addr)
+	defer solving.Close()
+
+	// Act
+	tcpServer.Stop()
+	// the idle connection is closed right away
+	_, idleErr := bufio.NewReader(idle).ReadString('\n')
+	// the client that was solving its challenge still gets its quote
+	solvedStamp, err := stamp.ComputeHashcash(10000000)
+	assert.NoError(t, err)
+	solvedStampMarshaled, err := json.Marshal(solvedStamp)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+	_, err = solving.Write([]byte(quoteRequest.ToJsonString() + "\n"))
+	assert.NoError(t, err)
+	line, quoteErr := reader.ReadString('\n')
+	_, closedErr := reader.ReadString('\n')
+
+	// Assert
+	assert.Equal(t, io.EOF, idleErr)
+	assert.NoError(t, quoteErr)
+	quote, err := protocol.ParseMessage([]byte(line))
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.QuoteResponse, quote.Type)
+	assert.Equal(t, io.EOF, closedErr)
+	assert.Equal(t, server.ErrServerClosed, <-started)
+}
+
+func TestStopForceClosesStragglers(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tcpServer, started := startServer(ctx, server.WithShutdownTimeout(100*time.Millisecond))
+	addr := tcpServer.Addr().String()
+	// a client that never sends its solution
+	straggler, reader, _ := requestChallenge(t, addr)
+	defer straggler.Close()
+
+	// Act
+	cancel()
+	err := <-started
+	_, closedErr := reader.ReadString('\n')
+
+	// Assert
+	assert.True(t, errors.Is(err, server.ErrServerClosed))
+	assert.Contains(t, err.Error(), "force closed 1 connections")
+	assert.Equal(t, io.EOF, closedErr)
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err)
+}
+
+func TestStartWithAddressInUse(t *testing.T) {
+	// Arrange
+	_, port, err := net.SplitHostPort(serverAddr)
+	assert.NoError(t, err)
+	tcpServer := server.NewTCPServer("localhost", port, repository.NewInMemoryDB(), quotes.NewEmbeddedStore())
+
+	// Act
+	err = tcpServer.Start(context.Background())
+
 	// Assert
 	assert.Error(t, err)
+	assert.False(t, errors.Is(err, server.ErrServerClosed))
+	assert.Nil(t, tcpServer.Addr())
+}
+
+// readError - reads the Error message the server sends before closing the connection
+func readError(t *testing.T, conn net.Conn) *protocol.ErrorPayload {
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	msg, err := protocol.ParseMessage([]byte(line))
+	assert.NoError(t, err)
+	var payload *protocol.ErrorPayload
+	assert.True(t, errors.As(protocol.ParseError(msg), &payload))
+	return payload
+}
+
+func TestConnectionLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits server.LimitsConfig
+	}{
+		{name: "per ip", limits: server.LimitsConfig{MaxConnectionsPerIP: 2, RetryAfter: 3 * time.Second}},
+		{name: "global", limits: server.LimitsConfig{MaxConnections: 2, Policy: server.RejectWhenFull, RetryAfter: 3 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			tcpServer, _ := startServer(context.Background(), server.WithConnectionLimits(tt.limits))
+			defer tcpServer.Stop()
+			addr := tcpServer.Addr().String()
+			for i := 0; i < 2; i++ {
+				conn, err := net.Dial("tcp", addr)
+				assert.NoError(t, err)
+				defer conn.Close()
+			}
+
+			// Act
+			rejected, err := net.Dial("tcp", addr)
+			assert.NoError(t, err)
+			defer rejected.Close()
+			payload := readError(t, rejected)
+
+			// Assert
+			assert.True(t, errors.Is(payload, protocol.ErrTooManyConnections))
+			assert.Equal(t, 3, payload.RetryAfter)
+		})
+	}
+}
+
+func TestConnectionLimitsWaitWhenFull(t *testing.T) {
+	// Arrange
+	tcpServer, _ := startServer(context.Background(), server.WithConnectionLimits(server.LimitsConfig{MaxConnections: 1, Policy: server.WaitWhenFull}))
+	defer tcpServer.Stop()
+	addr := tcpServer.Addr().String()
+	first, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	waiting, err := net.Dial("tcp", addr)
+	assert.NoError(t, err)
+	defer waiting.Close()
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	_, err = waiting.Write([]byte(challengeRequest.ToJsonString() + "\n"))
+	assert.NoError(t, err)
+	reader := bufio.NewReader(waiting)
+
+	// Act
+	assert.NoError(t, waiting.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, queuedErr := reader.ReadString('\n')
+	first.Close()
+	assert.NoError(t, waiting.SetReadDeadline(time.Time{}))
+	line, err := reader.ReadString('\n')
+
+	// Assert
+	var netErr net.Error
+	assert.True(t, errors.As(queuedErr, &netErr) && netErr.Timeout())
+	assert.NoError(t, err)
+	msg, err := protocol.ParseMessage([]byte(line))
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.ChallengeResponse, msg.Type)
+}
+
+func TestConnectionTimeouts(t *testing.T) {
+	tcpServer, _ := startServer(context.Background(), server.WithTimeouts(server.TimeoutsConfig{
+		Handshake: 50 * time.Millisecond,
+		Read:      50 * time.Millisecond,
+		Write:     time.Second,
+		Idle:      50 * time.Millisecond,
+		Solve:     50 * time.Millisecond,
+	}), server.WithSolveWindow(50*time.Millisecond))
+	defer tcpServer.Stop()
+	hello := protocol.NewHelloMessage(protocol.HelloPayload{
+		Versions:   []int{protocol.Version},
+		Encodings:  []protocol.Encoding{protocol.EncodingJSON},
+		Algorithms: []string{pow.SHA1Hashcash},
+	})
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+
+	tests := []struct {
+		name    string
+		send    string
+		replies int
+		wanted  string
+	}{
+		{name: "handshake", wanted: "no first message"},
+		{name: "slowloris", send: `{"type":`, wanted: "message not completed"},
+		{name: "idle", send: hello.ToJsonString() + "\n", replies: 1, wanted: "no request"},
+		{name: "solve", send: challengeRequest.ToJsonString() + "\n", replies: 1, wanted: "no solution"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			conn, err := net.Dial("tcp", tcpServer.Addr().String())
+			assert.NoError(t, err)
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+
+			// Act
+			_, err = conn.Write([]byte(tt.send))
+			assert.NoError(t, err)
+			for i := 0; i < tt.replies; i++ {
+				_, err := reader.ReadString('\n')
+				assert.NoError(t, err)
+			}
+			line, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			_, closedErr := reader.ReadString('\n')
+
+			// Assert
+			msg, err := protocol.ParseMessage([]byte(line))
+			assert.NoError(t, err)
+			err = protocol.ParseError(msg)
+			assert.True(t, errors.Is(err, protocol.ErrTimeout))
+			assert.Contains(t, err.Error(), tt.wanted)
+			assert.Equal(t, io.EOF, closedErr)
+		})
+	}
+}
+
+func TestSolveTimeoutFollowsSolveWindow(t *testing.T) {
+	// Arrange
+	tcpServer, _ := startServer(context.Background(), server.WithTimeouts(server.TimeoutsConfig{
+		Handshake: time.Second,
+		Read:      time.Second,
+		Write:     time.Second,
+		Idle:      time.Second,
+		Solve:     50 * time.Millisecond,
+	}), server.WithSolveWindow(time.Second))
+	defer tcpServer.Stop()
+	conn, reader, stamp := requestChallenge(t, tcpServer.Addr().String())
+	defer conn.Close()
+	solvedStamp, err := stamp.ComputeHashcash(10000000)
+	assert.NoError(t, err)
+	solvedStampMarshaled, err := json.Marshal(solvedStamp)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+
+	// Act
+	// longer than the Solve timeout, within the solve window
+	time.Sleep(200 * time.Millisecond)
+	_, err = conn.Write([]byte(quoteRequest.ToJsonString() + "\n"))
+	assert.NoError(t, err)
+	line, err := reader.ReadString('\n')
+
+	// Assert
+	assert.NoError(t, err)
+	msg, err := protocol.ParseMessage([]byte(line))
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.QuoteResponse, msg.Type)
+}
+
+func TestProcessQuoteRequestAfterSolveWindow(t *testing.T) {
+	signer, err := challenge.NewSigner(challenge.DefaultConfig())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		opts   []server.Option
+		window time.Duration
+	}{
+		{name: "stored", opts: []server.Option{server.WithSolveWindow(100 * time.Millisecond)}, window: 100 * time.Millisecond},
+		// signed dates only have whole seconds
+		{name: "signed", opts: []server.Option{server.WithSolveWindow(time.Second), server.WithStatelessChallenges(signer)}, window: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			tcpServer := server.NewTCPServer("", "", repository.NewInMemoryDB(), quotes.NewEmbeddedStore(), tt.opts...)
+			challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+			msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "testClient")
+			assert.NoError(t, err)
+			var stamp hashcash.Stamp
+			assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+			solvedStamp, err := stamp.ComputeHashcash(10000000)
+			assert.NoError(t, err)
+			solvedStampMarshaled, err := json.Marshal(solvedStamp)
+			assert.NoError(t, err)
+			quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+			time.Sleep(tt.window + 100*time.Millisecond)
+
+			// Act
+			_, err = tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "testClient")
+
+			// Assert
+			assert.Equal(t, 1, stamp.SolveWindow)
+			assert.True(t, errors.Is(err, protocol.ErrChallengeExpired))
+		})
+	}
+}
+
+func TestProcessQuoteRequestProtocolVersion(t *testing.T) {
+	store, err := quotes.NewStore(quotes.Quote{ID: "pike-clear", Text: "Clear is better than clever.", Author: "Rob Pike", Language: "en"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		wantedData string
+	}{
+		{name: "no handshake", ctx: context.Background(), wantedData: "Clear is better than clever. — Rob Pike"},
+		{name: "version 1", ctx: server.ContextWithProtocolVersion(context.Background(), 1), wantedData: "Clear is better than clever. — Rob Pike"},
+		{
+			name:       "structured quotes",
+			ctx:        server.ContextWithProtocolVersion(context.Background(), protocol.StructuredQuotesVersion),
+			wantedData: `{"id":"pike-clear","text":"Clear is better than clever.","author":"Rob Pike","language":"en"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			tcpServer := server.NewTCPServer("", "", repository.NewInMemoryDB(), store)
+			quoteRequest := solveChallenge(t, tt.ctx, tcpServer, "10.0.0.1:1000")
+
+			// Act
+			msg, err := tcpServer.ProcessRequest(tt.ctx, quoteRequest.ToJsonString(), "10.0.0.1:1000")
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, protocol.QuoteResponse, msg.Type)
+			assert.Equal(t, tt.wantedData, msg.Data)
+		})
+	}
+}
+
+func TestProcessQuoteRequestFilter(t *testing.T) {
+	store, err := quotes.NewStore(
+		quotes.Quote{ID: "pike", Text: "Clear is better than clever.", Author: "Rob Pike", Tags: []string{"engineering"}, Language: "en"},
+		quotes.Quote{ID: "seneca", Text: "While we are postponing, life speeds by.", Author: "Seneca", Tags: []string{"stoicism"}, Language: "en"},
+		quotes.Quote{ID: "seneca-de", Text: "Während wir aufschieben, eilt das Leben vorbei.", Author: "Seneca", Tags: []string{"stoicism"}, Language: "de"},
+	)
+	assert.NoError(t, err)
+	ctx := server.ContextWithProtocolVersion(context.Background(), protocol.StructuredQuotesVersion)
+
+	tests := []struct {
+		name      string
+		filter    protocol.QuoteFilter
+		wantedID  string
+		wantedErr error
+	}{
+		{name: "by id", filter: protocol.QuoteFilter{ID: "seneca"}, wantedID: "seneca"},
+		{name: "by tag", filter: protocol.QuoteFilter{Tag: "engineering"}, wantedID: "pike"},
+		{name: "by author in a language", filter: protocol.QuoteFilter{Author: "seneca", Languages: []string{"de-AT", "en"}}, wantedID: "seneca-de"},
+		{name: "language falls back", filter: protocol.QuoteFilter{Tag: "engineering", Languages: []string{"de"}}, wantedID: "pike"},
+		{name: "no match", filter: protocol.QuoteFilter{Tag: "cooking"}, wantedErr: protocol.ErrQuoteNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			tcpServer := server.NewTCPServer("", "", repository.NewInMemoryDB(), store)
+			solved := solveChallenge(t, ctx, tcpServer, "10.0.0.1:1000")
+			quoteRequest, err := protocol.NewQuoteRequestMessage(json.RawMessage(solved.Data), tt.filter)
+			assert.NoError(t, err)
+
+			// Act
+			msg, err := tcpServer.ProcessRequest(ctx, quoteRequest.ToJsonString(), "10.0.0.1:1000")
+
+			// Assert
+			if tt.wantedErr != nil {
+				assert.True(t, errors.Is(err, tt.wantedErr))
+				// the solution isn't spent on a filter matching nothing
+				msg, err = tcpServer.ProcessRequest(ctx, solved.ToJsonString(), "10.0.0.1:1000")
+				assert.NoError(t, err)
+				assert.Equal(t, protocol.QuoteResponse, msg.Type)
+				return
+			}
+			assert.NoError(t, err)
+			quote, err := protocol.ParseQuoteResponse(protocol.StructuredQuotesVersion, msg)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantedID, quote.ID)
+		})
+	}
+}
+
+func TestProcessQuoteRequestWithSelector(t *testing.T) {
+	// Arrange
+	store, err := quotes.NewStore(quotes.Quote{ID: "a", Text: "one"}, quotes.Quote{ID: "b", Text: "two"}, quotes.Quote{ID: "c", Text: "three"})
+	assert.NoError(t, err)
+	selector := quotes.NewShuffleSelector(rand.New(rand.NewSource(42)), 10)
+	tcpServer := server.NewTCPServer("", "", repository.NewInMemoryDB(), store, server.WithSelector(selector))
+	ctx := server.ContextWithProtocolVersion(context.Background(), protocol.StructuredQuotesVersion)
+
+	// Act
+	var served []string
+	for port := 1000; port < 1003; port++ {
+		// every connection of a client comes from another port
+		clientDetails := fmt.Sprintf("10.0.0.1:%d", port)
+		quoteRequest := solveChallenge(t, ctx, tcpServer, clientDetails)
+		msg, err := tcpServer.ProcessRequest(ctx, quoteRequest.ToJsonString(), clientDetails)
+		assert.NoError(t, err)
+		quote, err := protocol.ParseQuoteResponse(protocol.StructuredQuotesVersion, msg)
+		assert.NoError(t, err)
+		served = append(served, quote.ID)
+	}
+
+	// Assert
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, served)
+}
+
+// expectedAttempts - how many attempts solving the stamp takes on average, 16 per hex zero or 2 per bit
+func expectedAttempts(stamp hashcash.Stamp) float64 {
+	if stamp.Version == hashcash.VersionHexZeros {
+		return math.Pow(16, float64(stamp.ZerosCount))
+	}
+	return math.Pow(2, float64(stamp.ZerosCount))
+}
+
+func TestChallengeDifficultyAcrossAlgorithms(t *testing.T) {
+	tests := []struct {
+		name           string
+		alg            pow.Algorithm
+		wantedAttempts float64
+	}{
+		// the work of the default 5 hex zeros
+		{name: "sha1 hex zeros", alg: pow.NewSHA1Hashcash(), wantedAttempts: math.Pow(2, 20)},
+		{name: "sha1 bits", alg: pow.NewSHA1HashcashBits(), wantedAttempts: math.Pow(2, 20)},
+		{name: "sha256", alg: pow.NewSHA256Hashcash(), wantedAttempts: math.Pow(2, 20)},
+		// every attempt costs 2^13 hashes
+		{name: "argon2id", alg: pow.NewArgon2id(), wantedAttempts: math.Pow(2, 7)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			tcpServer := server.NewTCPServer("", "", repository.NewInMemoryDB(), quotes.NewEmbeddedStore(), server.WithAlgorithm(tt.alg))
+			challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+
+			// Act
+			msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.0.1:1000")
+
+			// Assert
+			assert.NoError(t, err)
+			var stamp hashcash.Stamp
+			assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+			assert.Equal(t, tt.alg.Name(), stamp.Algorithm)
+			assert.Equal(t, tt.wantedAttempts, expectedAttempts(stamp))
+		})
+	}
+}
+
+func TestArgon2idChallengeSolvableWithinSolveWindow(t *testing.T) {
+	// Arrange
+	alg := pow.NewArgon2id()
+	tcpServer := server.NewTCPServer("", "", repository.NewInMemoryDB(), quotes.NewEmbeddedStore(), server.WithAlgorithm(alg))
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	msg, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.0.1:1000")
+	assert.NoError(t, err)
+	var stamp hashcash.Stamp
+	assert.NoError(t, json.Unmarshal([]byte(msg.Data), &stamp))
+
+	// Act
+	start := time.Now()
+	solvedStamp, solveErr := alg.Solve(stamp, 0)
+	elapsed := time.Since(start)
+	solvedStampMarshaled, err := json.Marshal(solvedStamp)
+	assert.NoError(t, err)
+	quoteRequest := protocol.Message{Type: protocol.QuoteRequest, Data: string(solvedStampMarshaled)}
+	msg, redeemErr := tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "10.0.0.1:1000")
+
+	// Assert
+	assert.NoError(t, solveErr)
+	assert.Less(t, elapsed, server.DefaultSolveWindow)
+	assert.NoError(t, redeemErr)
+	assert.Equal(t, protocol.QuoteResponse, msg.Type)
+}
+
+func TestPendingChallengesCannotEvictOtherClients(t *testing.T) {
+	// Arrange
+	repo := repository.NewInMemoryDBWithConfig(repository.Config{Capacity: 10, Eviction: repository.EvictOldest})
+	tcpServer := server.NewTCPServer("", "", repo, quotes.NewEmbeddedStore(), server.WithMaxPendingChallenges(5))
+	quoteRequest := solveChallenge(t, context.Background(), tcpServer, "10.0.0.1:1000")
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+
+	// Act
+	// a single subnet requesting challenges from ever new connections
+	var issued int
+	var rateLimited error
+	for port := 1000; port < 1020; port++ {
+		_, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), fmt.Sprintf("10.0.1.1:%d", port))
+		if err != nil {
+			rateLimited = err
+			continue
+		}
+		issued++
+	}
+	msg, err := tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "10.0.0.1:1000")
+
+	// Assert
+	assert.Equal(t, 5, issued)
+	assert.True(t, errors.Is(rateLimited, protocol.ErrRateLimited))
+	var payload *protocol.ErrorPayload
+	assert.True(t, errors.As(rateLimited, &payload))
+	assert.Greater(t, payload.RetryAfter, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, protocol.QuoteResponse, msg.Type)
+}
+
+func TestRedeemedChallengesFreeThePendingQuota(t *testing.T) {
+	// Arrange
+	tcpServer := server.NewTCPServer("", "", repository.NewInMemoryDB(), quotes.NewEmbeddedStore(), server.WithMaxPendingChallenges(1))
+	quoteRequest := solveChallenge(t, context.Background(), tcpServer, "10.0.0.1:1000")
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	_, pendingErr := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.0.1:1001")
+
+	// Act
+	_, redeemErr := tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "10.0.0.1:1000")
+	_, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.0.1:1001")
+
+	// Assert
+	assert.True(t, errors.Is(pendingErr, protocol.ErrRateLimited))
+	assert.NoError(t, redeemErr)
+	assert.NoError(t, err)
+}
+
+func TestRedeemedChallengesFreeTheIssuingSubnetsQuota(t *testing.T) {
+	// Arrange
+	// unbound challenges can be redeemed from any address
+	tcpServer := server.NewTCPServer("", "", repository.NewInMemoryDB(), quotes.NewEmbeddedStore(),
+		server.WithMaxPendingChallenges(1), server.WithBinding(server.BindNone))
+	quoteRequest := solveChallenge(t, context.Background(), tcpServer, "10.0.0.1:1000")
+	challengeRequest := protocol.Message{Type: protocol.ChallengeRequest, Data: "empty"}
+	_, err := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.1.1:1000")
+	assert.NoError(t, err)
+
+	// Act
+	_, redeemErr := tcpServer.ProcessRequest(context.Background(), quoteRequest.ToJsonString(), "10.0.1.1:1000")
+	_, issuerErr := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.0.1:1001")
+	_, redeemerErr := tcpServer.ProcessRequest(context.Background(), challengeRequest.ToJsonString(), "10.0.1.1:1001")
+
+	// Assert
+	assert.NoError(t, redeemErr)
+	assert.NoError(t, issuerErr)
+	// the redeemer's own challenge is still pending
+	assert.True(t, errors.Is(redeemerErr, protocol.ErrRateLimited))
 }
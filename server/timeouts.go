@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/Lockwarr/WordOfWisdom/internal/protocol"
+)
+
+// errStopping - an idle connection noticed the shutdown before it started waiting for the next message
+var errStopping = errors.New("server is stopping")
+
+// TimeoutsConfig - bounds how long a connection can hold a handler without making progress, zero disables a timeout
+type TimeoutsConfig struct {
+	// Handshake is how long a new connection has to start sending its first message, a Hello or a request
+	Handshake time.Duration
+	// Read is how long a message has to arrive completely once it started arriving
+	Read time.Duration
+	// Write is how long sending a single message can take, i.e. how long a client that doesn't read is waited for
+	Write time.Duration
+	// Idle is how long the next request is waited for once an exchange is complete
+	Idle time.Duration
+	// Solve is how long the solution is waited for once a challenge was issued, never shorter than the solve window
+	Solve time.Duration
+}
+
+// DefaultTimeoutsConfig - returns the configuration used when none is provided
+func DefaultTimeoutsConfig() TimeoutsConfig {
+	return TimeoutsConfig{
+		Handshake: 10 * time.Second,
+		Read:      10 * time.Second,
+		Write:     10 * time.Second,
+		Idle:      time.Minute,
+		// as long as challenges can be redeemed
+		Solve: DefaultSolveWindow,
+	}
+}
+
+// timeoutWriter - renews the write deadline before every write, so a client that stops reading can't block a handler
+type timeoutWriter struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	if err := w.conn.SetWriteDeadline(deadline(w.timeout)); err != nil {
+		return 0, err
+	}
+	return w.conn.Write(p)
+}
+
+// awaitMessage - waits for the next message to start arriving within the timeout, then gives it the read timeout
+// to arrive completely. Timeouts are reported as protocol errors naming what was waited for.
+func (s *tcpServer) awaitMessage(conn net.Conn, reader *bufio.Reader, state *connState, waitFor string, timeout time.Duration) error {
+	if err := conn.SetReadDeadline(deadline(timeout)); err != nil {
+		return err
+	}
+	// after the deadline is set, so the drain's deadline can't be overwritten unnoticed
+	if atomic.LoadInt32(&state.busy) == 0 && s.stopping() {
+		return errStopping
+	}
+	if _, err := reader.Peek(1); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return protocol.NewError(protocol.CodeTimeout, "no %s within %s", waitFor, timeout)
+		}
+		return err
+	}
+	return conn.SetReadDeadline(deadline(s.timeouts.Read))
+}
+
+// deadline - the deadline of a timeout starting now, none for a zero timeout
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}